@@ -0,0 +1,105 @@
+package ginzap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriterConfig is config setting for NewAsyncWriter.
+type AsyncWriterConfig struct {
+	// QueueSize is how many log lines may wait to be written. Defaults to
+	// 1024.
+	QueueSize int
+	// Block makes requests wait for room when the queue is full, instead of
+	// dropping their line.
+	Block bool
+}
+
+// AsyncWriter writes access log lines from a background goroutine, so that a
+// slow sink does not add to the latency of the requests. It is enabled
+// through Config.Async.
+//
+// Lines are written in the order they were queued. When the queue is full
+// they are dropped, and counted by Dropped, unless Block is set. Lines of
+// requests ending after Close are written synchronously.
+type AsyncWriter struct {
+	block   bool
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan func()
+	done   chan struct{}
+}
+
+// NewAsyncWriter starts an AsyncWriter. Call Close on shutdown to write the
+// lines still queued.
+func NewAsyncWriter(conf *AsyncWriterConfig) *AsyncWriter {
+	queueSize := 1024
+	if conf.QueueSize > 0 {
+		queueSize = conf.QueueSize
+	}
+	w := &AsyncWriter{
+		block: conf.Block,
+		queue: make(chan func(), queueSize),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Dropped returns how many lines were dropped because the queue was full.
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Flush waits until the lines queued before the call are written.
+func (w *AsyncWriter) Flush() {
+	written := make(chan struct{})
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return
+	}
+	w.queue <- func() { close(written) }
+	w.mu.RUnlock()
+	<-written
+}
+
+// Close writes the queued lines and stops the writer.
+func (w *AsyncWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+// enqueue queues write, or calls it right away once the writer is closed.
+func (w *AsyncWriter) enqueue(write func()) {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		write()
+		return
+	}
+	defer w.mu.RUnlock()
+	if w.block {
+		w.queue <- write
+		return
+	}
+	select {
+	case w.queue <- write:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for write := range w.queue {
+		write()
+	}
+}
@@ -0,0 +1,79 @@
+package ginzap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// blockingLogger signals entered on every Info call and holds it until
+// release is closed.
+type blockingLogger struct {
+	ZapLogger
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (l blockingLogger) Info(msg string, fields ...zap.Field) {
+	l.entered <- struct{}{}
+	<-l.release
+	l.ZapLogger.Info(msg, fields...)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	async := NewAsyncWriter(&AsyncWriterConfig{})
+	defer async.Close()
+	r.Use(GinzapWithConfig(logger, &Config{Async: async}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath+"?a=1", nil)
+	r.ServeHTTP(res, req)
+	async.Flush()
+
+	if observed.Len() != 1 {
+		t.Fatalf("the line should be written by Flush but got %d entries", observed.Len())
+	}
+	if fields := observed.All()[0].ContextMap(); fields["status"] != int64(204) || fields["query"] != "a=1" {
+		t.Fatalf("the line should keep its fields but was %v", fields)
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	r := gin.New()
+
+	zl, observed := buildDummyLogger()
+	logger := blockingLogger{ZapLogger: zl, entered: make(chan struct{}, 3), release: make(chan struct{})}
+	async := NewAsyncWriter(&AsyncWriterConfig{QueueSize: 1})
+	r.Use(GinzapWithConfig(logger, &Config{Async: async}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	// The first line blocks the writer, the second fills the queue and the
+	// third is dropped.
+	for i := 0; i < 3; i++ {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", testPath, nil)
+		r.ServeHTTP(res, req)
+		if i == 0 {
+			<-logger.entered
+		}
+	}
+	close(logger.release)
+	async.Close()
+
+	if async.Dropped() != 1 || observed.Len() != 2 {
+		t.Fatalf("one line should be dropped and two written, got %d dropped and %d written", async.Dropped(), observed.Len())
+	}
+}
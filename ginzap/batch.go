@@ -0,0 +1,145 @@
+package ginzap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BodyBatcherConfig is config setting for NewBodyBatcher.
+type BodyBatcherConfig struct {
+	// QueueSize is how many requests' bodies may wait to be logged. Bodies
+	// arriving while the queue is full are dropped. Defaults to 1024.
+	QueueSize int
+	// BatchSize is how many queued bodies are logged at once. Defaults to 64.
+	BatchSize int
+	// FlushInterval is how often a partial batch is logged. Defaults to one
+	// second.
+	FlushInterval time.Duration
+}
+
+// BodyBatcher logs captured bodies from a background goroutine, in batches,
+// so that encoding them stays off the request path. It is enabled through
+// Config.BodyBatcher.
+//
+// Bodies end up in a second stream, joined to the access log on "body-ref":
+// the access log line of each request carries a "body-ref" and the batcher
+// later logs a "request body" line with the same "body-ref" and the body
+// fields. Delivery is best-effort: when the queue is full the bodies are
+// dropped and the access log line carries "body-dropped": true instead, and
+// bodies still queued when the process exits without calling Close are lost.
+type BodyBatcher struct {
+	logger    ZapLogger
+	batchSize int
+	interval  time.Duration
+
+	prefix string
+	seq    atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan batchedBody
+	done   chan struct{}
+}
+
+type batchedBody struct {
+	ref    string
+	names  *fieldNamer
+	fields func() []zapcore.Field
+}
+
+// NewBodyBatcher starts a BodyBatcher logging to logger. Call Close on
+// shutdown to log the bodies still queued.
+func NewBodyBatcher(logger ZapLogger, conf *BodyBatcherConfig) *BodyBatcher {
+	queueSize, batchSize, interval := 1024, 64, time.Second
+	if conf.QueueSize > 0 {
+		queueSize = conf.QueueSize
+	}
+	if conf.BatchSize > 0 {
+		batchSize = conf.BatchSize
+	}
+	if conf.FlushInterval > 0 {
+		interval = conf.FlushInterval
+	}
+
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	batcher := &BodyBatcher{
+		logger:    logger,
+		batchSize: batchSize,
+		interval:  interval,
+		prefix:    hex.EncodeToString(b[:]) + "-",
+		queue:     make(chan batchedBody, queueSize),
+		done:      make(chan struct{}),
+	}
+	go batcher.run()
+	return batcher
+}
+
+// Close logs the queued bodies and stops the batcher. Bodies of requests
+// ending after Close are dropped.
+func (b *BodyBatcher) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+// enqueue queues fields to be logged, returning the reference joining them
+// to the access log, or false if they were dropped.
+func (b *BodyBatcher) enqueue(names *fieldNamer, fields func() []zapcore.Field) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return "", false
+	}
+	ref := b.prefix + strconv.FormatUint(b.seq.Add(1), 10)
+	select {
+	case b.queue <- batchedBody{ref: ref, names: names, fields: fields}:
+		return ref, true
+	default:
+		return "", false
+	}
+}
+
+func (b *BodyBatcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]batchedBody, 0, b.batchSize)
+	for {
+		select {
+		case body, ok := <-b.queue:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			if batch = append(batch, body); len(batch) == b.batchSize {
+				batch = b.flush(batch)
+			}
+		case <-ticker.C:
+			batch = b.flush(batch)
+		}
+	}
+}
+
+func (b *BodyBatcher) flush(batch []batchedBody) []batchedBody {
+	for i, body := range batch {
+		fields := body.fields()
+		body.names.rename(fields)
+		b.logger.Info("request body", append([]zapcore.Field{zap.String(body.names.key("body-ref"), body.ref)}, fields...)...)
+		// Let the bodies be collected before the slot is reused.
+		batch[i] = batchedBody{}
+	}
+	return batch[:0]
+}
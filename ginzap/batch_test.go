@@ -0,0 +1,67 @@
+package ginzap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyBatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	bodyLogger, bodies := buildDummyLogger()
+	batcher := NewBodyBatcher(bodyLogger, &BodyBatcherConfig{BatchSize: 2, FlushInterval: time.Hour})
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		BodyBatcher:     batcher,
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.Data(200, "application/json", body)
+	})
+
+	send := func(i int) {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", testPath, strings.NewReader(fmt.Sprintf(`{"n":%d}`, i)))
+		r.ServeHTTP(res, req)
+	}
+	for i := 0; i < 3; i++ {
+		send(i)
+	}
+	batcher.Close()
+	send(3)
+
+	if len(bodies.All()) != 3 {
+		t.Fatalf("expected 3 batched body lines but got %d", len(bodies.All()))
+	}
+	for i, entry := range observed.All()[:3] {
+		fields := entry.ContextMap()
+		if _, ok := fields["request-body"]; ok {
+			t.Fatal("bodies should not be logged inline")
+		}
+		body := bodies.All()[i].ContextMap()
+		if body["body-ref"] != fields["body-ref"] || fields["body-ref"] == nil {
+			t.Fatalf("body line %d should join on body-ref %v but had %v", i, fields["body-ref"], body["body-ref"])
+		}
+		want := fmt.Sprintf(`{"n":%d}`, i)
+		if body["request-body"] != want || body["response-body"] != want {
+			t.Fatalf("body line %d logged unexpected bodies: %v", i, body)
+		}
+	}
+
+	if fields := observed.All()[3].ContextMap(); fields["body-dropped"] != true {
+		t.Fatalf("bodies should be dropped after Close: %v", fields)
+	}
+}
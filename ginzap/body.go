@@ -0,0 +1,514 @@
+package ginzap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logBodyKey is the context key set by LogBody.
+const logBodyKey = "_ginzap/log-body"
+
+// LogBody marks the current request for body logging. It only has an effect
+// when Config.BodyLoggingOptIn is on, in which case captured bodies are logged
+// for marked requests only.
+func LogBody(c *gin.Context) {
+	c.Set(logBodyKey, true)
+}
+
+// responseBodyKey is the context key holding the response body capture.
+const responseBodyKey = "_ginzap/response-body"
+
+// ResponseBody returns the response body written so far, as captured for
+// LogResponseBody, e.g. for a middleware registered after the access logger
+// that signs or caches responses once c.Next returns. It is only populated
+// when response capture is on for the request, holds at most
+// MaxResponseBodySize bytes, and reports false after a hijack or for a type
+// left out by ResponseBodyContentTypes. The bytes are reused once the access
+// logger returns: copy them to keep them.
+func ResponseBody(c *gin.Context) ([]byte, bool) {
+	v, _ := c.Get(responseBodyKey)
+	blw, ok := v.(*bodyLogWriter)
+	if !ok || blw.hijacked || blw.skipped {
+		return nil, false
+	}
+	return blw.body.Bytes(), true
+}
+
+// bodyRestoreIssueKey is the context key set by ReportBodyRestoreIssue.
+const bodyRestoreIssueKey = "_ginzap/body-restore-issue"
+
+// ReportBodyRestoreIssue flags the current request as having failed to read
+// a request body restored after capture. Handlers call it when reading or
+// parsing c.Request.Body fails in a way they suspect is caused by body
+// logging; the access log then carries "body-restore-issue": true.
+func ReportBodyRestoreIssue(c *gin.Context) {
+	c.Set(bodyRestoreIssueKey, true)
+}
+
+// restoredBody replaces a request body consumed for logging. Unlike
+// io.NopCloser, Close is honored: reading after Close fails as it does for
+// the original body, and is reported as a body restore issue.
+type restoredBody struct {
+	r      io.Reader
+	rest   io.Closer
+	c      *gin.Context
+	closed bool
+}
+
+// newRestoredBody returns a body reading body, then rest if not nil. rest is
+// the partially read original body of a truncated capture.
+func newRestoredBody(c *gin.Context, body []byte, rest io.ReadCloser) *restoredBody {
+	if rest == nil {
+		return &restoredBody{r: bytes.NewReader(body), c: c}
+	}
+	return &restoredBody{r: io.MultiReader(bytes.NewReader(body), rest), rest: rest, c: c}
+}
+
+func (b *restoredBody) Read(p []byte) (int, error) {
+	if b.closed {
+		ReportBodyRestoreIssue(b.c)
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.r.Read(p)
+}
+
+func (b *restoredBody) Close() error {
+	b.closed = true
+	if b.rest != nil {
+		return b.rest.Close()
+	}
+	return nil
+}
+
+// captureRequestBody reads the request body for logging and restores it for
+// the handlers. With a positive limit, at most limit bytes are captured and
+// the remainder is left unread in the original body; truncated reports
+// whether there was a remainder. body is nil when the request has no body or
+// declares a zero Content-Length, and empty, but not nil, when a body of
+// unknown length turns out empty. When reading fails, the
+// bytes read so far are returned with the error, and the handlers read the
+// same bytes followed by the same error.
+//
+// A body already cached under gin.BodyBytesKey by an earlier middleware, as
+// c.ShouldBindBodyWith does, is reused rather than read again. Otherwise the
+// body is read into buf, and a body read whole is cached there in turn;
+// shared then reports that buf belongs to the request and must not return
+// to the pool.
+func captureRequestBody(c *gin.Context, buf *bytes.Buffer, limit int) (body []byte, truncated, shared bool, err error) {
+	if cached, ok := c.Get(gin.BodyBytesKey); ok {
+		if body, ok := cached.([]byte); ok {
+			if limit > 0 && len(body) > limit {
+				return body[:limit], true, false, nil
+			}
+			return body, false, false, nil
+		}
+	}
+	// A declared empty body is not read at all; a chunked body has an
+	// unknown length of -1 and is.
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+		return nil, false, false, nil
+	}
+	r := io.Reader(c.Request.Body)
+	if limit > 0 {
+		// Read one byte past the limit to tell whether anything is left.
+		r = io.LimitReader(r, int64(limit)+1)
+	}
+	_, err = buf.ReadFrom(r)
+	if body = buf.Bytes(); body == nil {
+		body = []byte{}
+	}
+	switch {
+	case err != nil:
+		c.Request.Body = &restoredBody{r: io.MultiReader(bytes.NewReader(body), errReader{err}), rest: c.Request.Body, c: c}
+		return body, false, false, err
+	case limit <= 0 || len(body) <= limit:
+		c.Request.Body = newRestoredBody(c, body, nil)
+		c.Set(gin.BodyBytesKey, body)
+		return body, false, true, nil
+	}
+	c.Request.Body = newRestoredBody(c, body, c.Request.Body)
+	return body[:limit], true, false, nil
+}
+
+// countingBody counts the bytes read from a request body for
+// Config.CountRequestBody.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// errReader fails every read with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// maxPooledBuffer is the capacity above which response body buffers are left
+// to the garbage collector rather than pooled, so one huge response does not
+// pin its memory.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns b to the pool. Nothing may reference its bytes anymore.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+// bodyLogWriter is a gin.ResponseWriter that keeps a copy of everything
+// written through it so the response body can be logged. With a positive
+// limit, it keeps at most limit bytes and records that the rest was dropped.
+// Flush, CloseNotify and Size are promoted from the embedded writer, so
+// streaming responses such as Server-Sent Events keep working.
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	body      *bytes.Buffer
+	limit     int
+	truncated bool
+	hijacked  bool
+	// contentTypes, when set, are the Content-Type prefixes of the responses
+	// to buffer, checked on the first write; skipped is set past it for
+	// responses of other types.
+	contentTypes []string
+	checked      bool
+	skipped      bool
+}
+
+// sniffLen is the most bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// keep reports whether the response is buffered, checking its Content-Type,
+// or else the type sniffed from b, against contentTypes on the first write.
+func (w *bodyLogWriter) keep(b []byte) bool {
+	if !w.checked && len(w.contentTypes) > 0 {
+		w.checked = true
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(b)
+		}
+		w.skipped = !mediaTypeMatches(w.contentTypes, contentType)
+	}
+	return !w.skipped
+}
+
+// room returns how many of n bytes may still be kept.
+func (w *bodyLogWriter) room(n int) int {
+	if w.limit <= 0 {
+		return n
+	}
+	if free := w.limit - w.body.Len(); free < n {
+		w.truncated = true
+		return free
+	}
+	return n
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	if w.keep(b) {
+		w.body.Write(b[:w.room(len(b))])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	if !w.checked && len(w.contentTypes) > 0 {
+		sniff := s
+		if len(sniff) > sniffLen {
+			sniff = sniff[:sniffLen]
+		}
+		w.keep([]byte(sniff))
+	}
+	if !w.skipped {
+		w.body.WriteString(s[:w.room(len(s))])
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ReadFrom implements io.ReaderFrom. It is used by io.Copy, and therefore by
+// http.ServeContent (c.File, c.FileAttachment, static routes), to stream file
+// contents. Those bytes are forwarded as-is and never buffered, and the
+// underlying ReadFrom is used when available to keep sendfile optimizations.
+func (w *bodyLogWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w.ResponseWriter}, r)
+}
+
+// Hijack implements http.Hijacker for WebSocket upgrades and the like. The
+// bytes exchanged over a hijacked connection bypass the writer, so once
+// hijacked the response body is not logged.
+func (w *bodyLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if !hijackable(w.ResponseWriter) {
+		return nil, nil, errNotHijacker
+	}
+	w.hijacked = true
+	return w.ResponseWriter.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var errNotHijacker = errors.New("ginzap: the response writer does not implement http.Hijacker")
+
+// hijackable reports whether the innermost writer wrapped by w supports
+// hijacking. gin.ResponseWriter always declares Hijack, but panics when the
+// writer it wraps does not implement http.Hijacker.
+func hijackable(w http.ResponseWriter) bool {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			_, ok := w.(http.Hijacker)
+			return ok
+		}
+		w = u.Unwrap()
+	}
+}
+
+// writerOnly hides every method but Write so io.Copy does not recurse into
+// ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
+// bodyFields returns the fields logging a captured body under key. Protobuf
+// bodies are first converted by Config.ProtoUnmarshaler. Bodies that are not
+// valid JSON are only logged, as-is, when contentType matches
+// Config.BodyContentTypes and is not a JSON type, and never when they would
+// have to be parsed for redaction or keys-only mode.
+func bodyFields(conf *Config, key, contentType string, body []byte) []zapcore.Field {
+	if decoded, ok := decodeProtoBody(conf, contentType, body); ok {
+		body = decoded
+	}
+	if !json.Valid(body) {
+		if !rawBodyAllowed(conf.BodyContentTypes, contentType) || redactsBody(conf) || conf.LogBodyKeysOnly {
+			return nil
+		}
+		return []zapcore.Field{zap.String(key, string(body))}
+	}
+	if conf.LogBodyKeysOnly {
+		if keys, ok := jsonObjectKeys(body); ok {
+			return []zapcore.Field{zap.Strings(key+"-keys", keys)}
+		}
+		return []zapcore.Field{zap.String(key+"-type", jsonType(body))}
+	}
+	body = redactBody(conf, body)
+	if conf.BodyAsObject {
+		// Copied, as the field may be encoded after body returns to its pool.
+		return []zapcore.Field{zap.Reflect(key, json.RawMessage(append([]byte(nil), body...)))}
+	}
+	if conf.PrettyBodies {
+		body = indentJSON(body)
+	}
+	return []zapcore.Field{zap.String(key, string(body))}
+}
+
+// indentJSON returns the valid JSON document body indented with two spaces.
+func indentJSON(body []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+// decodeProtoBody converts a protobuf body with Config.ProtoUnmarshaler.
+func decodeProtoBody(conf *Config, contentType string, body []byte) ([]byte, bool) {
+	if conf.ProtoUnmarshaler == nil || !isProtobufContentType(contentType) {
+		return nil, false
+	}
+	decoded, ok := conf.ProtoUnmarshaler(contentType, body)
+	if !ok || !json.Valid(decoded) {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// isProtobufContentType reports whether contentType is a protobuf media type
+// such as application/x-protobuf, application/protobuf or
+// application/vnd.google.protobuf.
+func isProtobufContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return strings.HasPrefix(mediaType, "application/") &&
+		(strings.HasSuffix(mediaType, "protobuf") || strings.HasSuffix(mediaType, "+proto"))
+}
+
+// rawBodyAllowed reports whether the media type of contentType starts with
+// one of the allowed prefixes, case-insensitively. JSON types never match so
+// invalid JSON bodies are still dropped.
+func rawBodyAllowed(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return false
+	}
+	for _, prefix := range allowed {
+		if prefix != "" && strings.HasPrefix(mediaType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypeMatches reports whether the media type of contentType starts with
+// one of the prefixes, case-insensitively.
+func mediaTypeMatches(prefixes []string, contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(mediaType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncatedBodyFields returns the fields logging a body cut off at the
+// capture limit: the "-truncated" marker, the "-size" of the whole body when
+// known (negative otherwise) and, unless redaction or keys-only mode needs to
+// parse it, the captured prefix as a string. The prefix is no longer valid
+// JSON, so it is logged as-is, without a partial rune at the end.
+func truncatedBodyFields(conf *Config, key string, body []byte, size int64) []zapcore.Field {
+	fields := []zapcore.Field{zap.Bool(key+"-truncated", true)}
+	if size >= 0 {
+		fields = append(fields, zap.Int64(key+"-size", size))
+	}
+	if !redactsBody(conf) && !conf.LogBodyKeysOnly {
+		fields = append(fields, zap.String(key, string(trimPartialRune(body))))
+	}
+	return fields
+}
+
+// prefixBodyFields returns the fields logging the first
+// Config.BodyPrefixBytes bytes of body, followed by "...", and the "-size" of
+// the whole body when known (negative otherwise). The prefix is omitted when
+// redaction or keys-only mode would need to parse the body.
+func prefixBodyFields(conf *Config, key string, body []byte, size int64) []zapcore.Field {
+	var fields []zapcore.Field
+	if size >= 0 {
+		fields = append(fields, zap.Int64(key+"-size", size))
+	}
+	if !redactsBody(conf) && !conf.LogBodyKeysOnly {
+		fields = append(fields, zap.String(key, string(trimPartialRune(body[:conf.BodyPrefixBytes]))+"..."))
+	}
+	return fields
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// jsonObjectKeys returns the top-level keys of a JSON object in document
+// order, or false if body is not an object.
+func jsonObjectKeys(body []byte) ([]string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	keys := []string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, false
+		}
+	}
+	return keys, true
+}
+
+// jsonFieldCount returns the number of members of a JSON object or elements
+// of a JSON array, or false for any other body.
+func jsonFieldCount(body []byte) (int, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	tok, err := dec.Token()
+	if err != nil || (tok != json.Delim('{') && tok != json.Delim('[')) {
+		return 0, false
+	}
+	object := tok == json.Delim('{')
+	n := 0
+	for dec.More() {
+		if object {
+			if _, err := dec.Token(); err != nil {
+				return 0, false
+			}
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return 0, false
+		}
+		n++
+	}
+	if _, err := dec.Token(); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// jsonType names the kind of a valid JSON document.
+func jsonType(body []byte) string {
+	switch b := bytes.TrimLeft(body, " \t\r\n"); {
+	case len(b) == 0:
+		return "empty"
+	case b[0] == '{':
+		return "object"
+	case b[0] == '[':
+		return "array"
+	case b[0] == '"':
+		return "string"
+	case b[0] == 't' || b[0] == 'f':
+		return "boolean"
+	case b[0] == 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
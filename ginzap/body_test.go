@@ -0,0 +1,1204 @@
+package ginzap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBodyLogWriterFileNotBuffered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A large but valid JSON document: if it were buffered it would be logged.
+	content := "[" + strings.Repeat(`"0123456789",`, 1<<16) + `"end"]`
+	file := filepath.Join(t.TempDir(), "large.json")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseBody: true}))
+
+	var written int
+	r.GET(testPath, func(c *gin.Context) {
+		c.File(file)
+		written = c.Writer.Size()
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if res.Body.Len() != len(content) {
+		t.Fatalf("response should be %d bytes but was %d", len(content), res.Body.Len())
+	}
+	if written != len(content) {
+		t.Fatalf("writer size should be %d but was %d", len(content), written)
+	}
+	if len(observed.All()) != 1 {
+		t.Fatalf("Log should be 1 line but there're %d", len(observed.All()))
+	}
+	if _, ok := observed.All()[0].ContextMap()["response-body"]; ok {
+		t.Fatal("file contents should not be buffered")
+	}
+}
+
+func TestBodyLogWriterWriteString(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true, LogResponseBody: true}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		_, _ = c.Writer.WriteString(string(body))
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewBufferString(`{"a":1}`))
+	r.ServeHTTP(res, req)
+
+	if res.Body.String() != `{"a":1}` {
+		t.Fatalf("handler should read the restored body but got %q", res.Body.String())
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["request-body"] != `{"a":1}` {
+		t.Fatalf("request-body should be logged but was %v", fields["request-body"])
+	}
+	if fields["response-body"] != `{"a":1}` {
+		t.Fatalf("response-body should be logged but was %v", fields["response-body"])
+	}
+}
+
+func TestMaxConcurrentBodyCaptures(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true, MaxConcurrentBodyCaptures: 1}))
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	r.POST("/slow", func(c *gin.Context) {
+		close(entered)
+		<-release
+		c.Status(204)
+	})
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(200, string(body))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequestWithContext(context.Background(), "POST", "/slow", bytes.NewBufferString(`{"slow":true}`))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "POST", testPath, bytes.NewBufferString(`{"a":1}`))
+	r.ServeHTTP(res, req)
+	if res.Body.String() != `{"a":1}` {
+		t.Fatalf("handler should still read the body but got %q", res.Body.String())
+	}
+
+	close(release)
+	<-done
+
+	if len(observed.All()) != 2 {
+		t.Fatalf("Log should be 2 lines but there're %d", len(observed.All()))
+	}
+	skipped := observed.All()[0].ContextMap()
+	if skipped["body-capture-skipped"] != "backpressure" {
+		t.Fatalf("body-capture-skipped should be backpressure but was %v", skipped["body-capture-skipped"])
+	}
+	if _, ok := skipped["request-body"]; ok {
+		t.Fatal("request-body should not be captured under backpressure")
+	}
+	if slow := observed.All()[1].ContextMap(); slow["request-body"] != `{"slow":true}` {
+		t.Fatalf("request-body should be captured below the limit but was %v", slow["request-body"])
+	}
+}
+
+func TestHexDumpBodyMaxSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true, HexDumpBodyMaxSize: 4}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, body := range []string{"\x00\x01\xfe\xff", `{"a":1}`} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewBufferString(body))
+		r.ServeHTTP(res, req)
+	}
+
+	small := observed.All()[0].ContextMap()
+	if small["request-body-hex"] != "0001feff" {
+		t.Fatalf("request-body-hex should be 0001feff but was %v", small["request-body-hex"])
+	}
+	if _, ok := small["request-body"]; ok {
+		t.Fatal("request-body should not be logged for hex-dumped bodies")
+	}
+
+	large := observed.All()[1].ContextMap()
+	if _, ok := large["request-body-hex"]; ok || large["request-body"] != `{"a":1}` {
+		t.Fatalf("bodies above the threshold should be logged normally, got %v", large)
+	}
+}
+
+func TestLogBodyKeysOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true, LogResponseBody: true, LogBodyKeysOnly: true}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Data(200, "application/json", []byte(`[1,2,3]`))
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewBufferString(`{"zeta":{"x":1},"alpha":"secret"}`))
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	keys, ok := fields["request-body-keys"].([]interface{})
+	if !ok || len(keys) != 2 || keys[0] != "zeta" || keys[1] != "alpha" {
+		t.Fatalf("request-body-keys should be [zeta alpha] but was %v", fields["request-body-keys"])
+	}
+	if fields["response-body-type"] != "array" {
+		t.Fatalf("response-body-type should be array but was %v", fields["response-body-type"])
+	}
+	for _, key := range []string{"request-body", "response-body"} {
+		if _, ok := fields[key]; ok {
+			t.Fatalf("%s should not be logged in keys-only mode", key)
+		}
+	}
+}
+
+func TestBodyContentTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:   true,
+		LogResponseBody:  true,
+		BodyContentTypes: []string{"text/", "application/x-www-form-urlencoded"},
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Data(200, "text/plain; charset=utf-8", []byte("pong"))
+	})
+	r.POST("/json", func(c *gin.Context) {
+		c.Data(200, "application/json", []byte("{broken"))
+	})
+
+	for _, tt := range []struct{ path, contentType, body string }{
+		{testPath, "application/x-www-form-urlencoded", "user=bob&age=4"},
+		{"/json", "text/plain", "{broken"},
+		{testPath, "application/xml", "<ping/>"},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["request-body"] != "user=bob&age=4" || fields["response-body"] != "pong" {
+		t.Fatalf("allowed bodies should be logged raw, got %v and %v", fields["request-body"], fields["response-body"])
+	}
+	fields = observed.All()[1].ContextMap()
+	if _, ok := fields["response-body"]; ok || fields["request-body"] != "{broken" {
+		t.Fatalf("invalid JSON responses should still be dropped, got %v", fields)
+	}
+	fields = observed.All()[2].ContextMap()
+	if _, ok := fields["request-body"]; ok {
+		t.Fatalf("content types outside the allowlist should be dropped, got %v", fields["request-body"])
+	}
+}
+
+func TestPrettyBodies(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:   true,
+		LogResponseBody:  true,
+		PrettyBodies:     true,
+		BodyContentTypes: []string{"text/"},
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Data(200, "text/plain", []byte("pong"))
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", testPath, strings.NewReader(`{"id":12345678901234567,"tags":["a"]}`))
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if want := "{\n  \"id\": 12345678901234567,\n  \"tags\": [\n    \"a\"\n  ]\n}"; fields["request-body"] != want {
+		t.Fatalf("request-body should be indented but was %q", fields["request-body"])
+	}
+	if fields["response-body"] != "pong" {
+		t.Fatalf("non-JSON bodies should be logged unchanged but was %q", fields["response-body"])
+	}
+}
+
+func TestResponseBodyContentTypes(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogResponseBody:          true,
+		ResponseBodyContentTypes: []string{"application/json"},
+	}))
+
+	var buffered []bool
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		body, ok := ResponseBody(c)
+		buffered = append(buffered, ok && len(body) > 0)
+	})
+	r.GET("/json", func(c *gin.Context) {
+		c.JSON(200, gin.H{"a": 1})
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(200, "image/png", []byte("\x89PNG\r\n\x1a\n"))
+	})
+	r.GET("/sniffed", func(c *gin.Context) {
+		_, _ = c.Writer.WriteString("\x89PNG\r\n\x1a\n")
+	})
+
+	for _, path := range []string{"/json", "/image", "/sniffed"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if body := observed.All()[0].ContextMap()["response-body"]; body != `{"a":1}` || !buffered[0] {
+		t.Fatalf("a JSON response should be buffered and logged, got %v", body)
+	}
+	for i, path := range []string{"/image", "/sniffed"} {
+		if _, ok := observed.All()[i+1].ContextMap()["response-body"]; ok || buffered[i+1] {
+			t.Fatalf("the response of %s should not be buffered", path)
+		}
+	}
+}
+
+func TestBodyAsObject(t *testing.T) {
+	r := gin.New()
+	var buf bytes.Buffer
+	logger := NewAccessLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		BodyAsObject:    true,
+		RedactBodyKeys:  []string{"password"},
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.JSON(200, []int{1, 2})
+	})
+
+	req := httptest.NewRequest("POST", testPath, strings.NewReader(`{"user":"u","password":"p","id":12345678901234567}`))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		RequestBody  map[string]json.RawMessage `json:"request-body"`
+		ResponseBody []int                      `json:"response-body"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("request-body should be a nested object: %v in %s", err, buf.String())
+	}
+	if string(line.RequestBody["password"]) != `"***"` || string(line.RequestBody["id"]) != "12345678901234567" {
+		t.Fatalf("request-body should be redacted and keep its numbers, got %s", buf.String())
+	}
+	if len(line.ResponseBody) != 2 {
+		t.Fatalf("response-body should be a nested array, got %s", buf.String())
+	}
+}
+
+func TestResponseBody(t *testing.T) {
+	r := gin.New()
+	logger, _ := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseBody: true}))
+
+	var got string
+	var ok bool
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		var body []byte
+		body, ok = ResponseBody(c)
+		got = string(body)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true})
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+	if !ok || got != `{"ok":true}` {
+		t.Fatalf("ResponseBody() = %q, %v", got, ok)
+	}
+
+	r = gin.New()
+	r.Use(GinzapWithConfig(logger, &Config{}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true})
+		_, ok = ResponseBody(c)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if ok {
+		t.Fatal("ResponseBody should report false without response capture")
+	}
+}
+
+func TestProtoUnmarshaler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		RedactBodyKeys:  []string{"token"},
+		ProtoUnmarshaler: func(contentType string, body []byte) (json.RawMessage, bool) {
+			if len(body) == 0 || body[0] != 0x0a {
+				return nil, false
+			}
+			return json.RawMessage(`{"name":"` + string(body[2:]) + `","token":"s3cret"}`), true
+		},
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Data(200, "application/x-protobuf", []byte{0x0a, 0x02, 'o', 'k'})
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewReader([]byte{0x0a, 0x03, 'b', 'o', 'b'}))
+	req.Header.Set("Content-Type", "application/protobuf")
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["request-body"] != `{"name":"bob","token":"***"}` || fields["response-body"] != `{"name":"ok","token":"***"}` {
+		t.Fatalf("protobuf bodies should be logged as redacted JSON, got %v and %v", fields["request-body"], fields["response-body"])
+	}
+}
+
+func TestIsProtobufContentType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/x-protobuf":          true,
+		"application/protobuf; proto=foo": true,
+		"application/vnd.google.protobuf": true,
+		"application/vnd.api+proto":       true,
+		"application/json":                false,
+		"text/protobuf":                   false,
+	} {
+		if got := isProtobufContentType(contentType); got != want {
+			t.Errorf("isProtobufContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestRawBodyAllowed(t *testing.T) {
+	allowed := []string{"text/", "Application/XML"}
+	for contentType, want := range map[string]bool{
+		"text/plain":               true,
+		"TEXT/HTML; charset=utf-8": true,
+		"application/xml":          true,
+		"application/json":         false,
+		"application/problem+json": false,
+		"application/octet-stream": false,
+		"":                         false,
+	} {
+		if got := rawBodyAllowed(allowed, contentType); got != want {
+			t.Errorf("rawBodyAllowed(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+	if rawBodyAllowed(nil, "text/plain") {
+		t.Error("nothing should be allowed by an empty list")
+	}
+}
+
+func TestJSONType(t *testing.T) {
+	tests := map[string]string{
+		`{}`:     "object",
+		` [1]`:   "array",
+		`"s"`:    "string",
+		`true`:   "boolean",
+		`null`:   "null",
+		`-1.5e3`: "number",
+	}
+	for body, want := range tests {
+		if got := jsonType([]byte(body)); got != want {
+			t.Errorf("jsonType(%s) = %s, want %s", body, got, want)
+		}
+	}
+}
+
+func TestBodyLoggingOptIn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true, LogResponseBody: true, BodyLoggingOptIn: true}))
+
+	r.POST("/safe", func(c *gin.Context) {
+		LogBody(c)
+		c.JSON(200, gin.H{"ok": true})
+	})
+	r.POST("/sensitive", func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true})
+	})
+
+	for _, path := range []string{"/safe", "/sensitive"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", path, bytes.NewBufferString(`{"a":1}`))
+		r.ServeHTTP(res, req)
+	}
+
+	safe := observed.All()[0].ContextMap()
+	if safe["request-body"] != `{"a":1}` || safe["response-body"] != `{"ok":true}` {
+		t.Fatalf("opted-in request should log bodies, got %v", safe)
+	}
+
+	sensitive := observed.All()[1].ContextMap()
+	for _, key := range []string{"request-body", "response-body"} {
+		if _, ok := sensitive[key]; ok {
+			t.Fatalf("%s should not be logged without LogBody", key)
+		}
+	}
+}
+
+func TestShouldLogBody(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:        true,
+		LogResponseBody:       true,
+		ShouldLogRequestBody:  func(c *gin.Context) bool { return c.FullPath() == "/orders" },
+		ShouldLogResponseBody: func(c *gin.Context) bool { return c.Request.Method == "POST" },
+	}))
+
+	var seen []string
+	handler := func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		seen = append(seen, string(body))
+		c.JSON(200, gin.H{"ok": true})
+	}
+	r.POST("/orders", handler)
+	r.POST("/import", handler)
+
+	for _, path := range []string{"/orders", "/import"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", path, bytes.NewBufferString(`{"a":1}`))
+		r.ServeHTTP(res, req)
+	}
+
+	if seen[0] != `{"a":1}` || seen[1] != `{"a":1}` {
+		t.Fatalf("handlers should read the body either way, got %q", seen)
+	}
+	orders := observed.All()[0].ContextMap()
+	if orders["request-body"] != `{"a":1}` || orders["response-body"] != `{"ok":true}` {
+		t.Fatalf("/orders should log both bodies, got %v", orders)
+	}
+	imports := observed.All()[1].ContextMap()
+	if _, ok := imports["request-body"]; ok || imports["response-body"] != `{"ok":true}` {
+		t.Fatalf("/import should only log the response body, got %v", imports)
+	}
+}
+
+func TestBodyOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:    true,
+		BodyOnError:       true,
+		BodyOnStatusCodes: []int{202},
+	}))
+
+	for _, status := range []int{200, 202, 422} {
+		status := status
+		r.POST(fmt.Sprintf("/%d", status), func(c *gin.Context) {
+			c.Status(status)
+		})
+	}
+	r.POST("/failed", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(200)
+	})
+
+	paths := []string{"/200", "/202", "/422", "/failed"}
+	for _, path := range paths {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", path, bytes.NewBufferString(`{"a":1}`))
+		r.ServeHTTP(res, req)
+	}
+
+	for i, want := range []bool{false, true, true, true} {
+		if _, ok := observed.All()[i].ContextMap()["request-body"]; ok != want {
+			t.Fatalf("%s: request-body presence should be %v", paths[i], want)
+		}
+	}
+}
+
+func TestJSONFieldCount(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+		ok   bool
+	}{
+		{`{}`, 0, true},
+		{`{"a":1,"b":{"c":[1,2]}}`, 2, true},
+		{`[1,[2,3],{"x":1}]`, 3, true},
+		{`"text"`, 0, false},
+		{`plain text`, 0, false},
+		{`{"a":1`, 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := jsonFieldCount([]byte(tt.body))
+		if n != tt.want || ok != tt.ok {
+			t.Errorf("jsonFieldCount(%s) = %d, %v, want %d, %v", tt.body, n, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLogResponseFieldCount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseFieldCount: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(200, gin.H{"id": 1, "name": "n"})
+	})
+	r.GET("/text", func(c *gin.Context) {
+		c.String(200, "hello")
+	})
+
+	for _, path := range []string{testPath, "/text"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["response-field-count"] != int64(2) {
+		t.Fatalf("response-field-count should be 2 but was %v", fields["response-field-count"])
+	}
+	if _, ok := fields["response-body"]; ok {
+		t.Fatal("response-body should not be logged without LogResponseBody")
+	}
+	if _, ok := observed.All()[1].ContextMap()["response-field-count"]; ok {
+		t.Fatal("response-field-count should be omitted for non-JSON responses")
+	}
+}
+
+func TestBodyRestoreIssue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil || string(body) != `{"a":1}` {
+			t.Errorf("restored body should be readable but got %q, %v", body, err)
+		}
+		c.Status(204)
+	})
+	r.POST("/closed", func(c *gin.Context) {
+		c.Request.Body.Close()
+		if _, err := c.Request.Body.Read(make([]byte, 1)); err != http.ErrBodyReadAfterClose {
+			t.Errorf("reading a closed body should fail but got %v", err)
+		}
+		c.Status(204)
+	})
+	r.POST("/reported", func(c *gin.Context) {
+		ReportBodyRestoreIssue(c)
+		c.Status(400)
+	})
+
+	for _, path := range []string{testPath, "/closed", "/reported"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", path, strings.NewReader(`{"a":1}`))
+		r.ServeHTTP(res, req)
+	}
+
+	if _, ok := observed.All()[0].ContextMap()["body-restore-issue"]; ok {
+		t.Fatal("body-restore-issue should be omitted when the body reads fine")
+	}
+	for _, entry := range observed.All()[1:] {
+		if entry.ContextMap()["body-restore-issue"] != true {
+			t.Fatalf("body-restore-issue should be logged for %v", entry.ContextMap()["path"])
+		}
+	}
+}
+
+// slowReader returns one byte per Read after sleeping for delay.
+type slowReader struct {
+	data  []byte
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p[:1], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestBodyReadLatency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const delay = 10 * time.Millisecond
+	body := `{"a":1}`
+	minRead := time.Duration(len(body)) * delay
+
+	for _, exclude := range []bool{false, true} {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, &Config{
+			LogRequestBody:         true,
+			LogBodyReadLatency:     true,
+			ExcludeBodyReadLatency: exclude,
+		}))
+		r.POST(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", testPath, &slowReader{data: []byte(body), delay: delay})
+		req.ContentLength = -1
+		r.ServeHTTP(res, req)
+
+		fields := observed.All()[0].ContextMap()
+		read := fields["body-read-latency"].(time.Duration)
+		latency := fields["latency"].(time.Duration)
+		if read < minRead {
+			t.Fatalf("body-read-latency should cover the slow read but was %v", read)
+		}
+		if exclude && latency >= minRead {
+			t.Fatalf("latency should exclude the body read but was %v", latency)
+		}
+		if !exclude && latency < read {
+			t.Fatalf("latency should include the body read but was %v", latency)
+		}
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:      true,
+		LogResponseBody:     true,
+		MaxRequestBodySize:  8,
+		MaxResponseBodySize: 5,
+	}))
+
+	reqBody := `{"name":"a long value"}`
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		if string(body) != reqBody {
+			t.Errorf("handlers should read the whole body but got %q", body)
+		}
+		// "héllo": the limit falls inside the two-byte "é".
+		c.String(200, "héllo wörld")
+	})
+	r.POST("/small", func(c *gin.Context) {
+		c.JSON(200, []int{1})
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, strings.NewReader(reqBody))
+	r.ServeHTTP(res, req)
+	if res.Body.String() != "héllo wörld" {
+		t.Fatalf("the whole response should be sent but got %q", res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "POST", "/small", strings.NewReader(`{}`))
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["request-body"] != `{"name":` || fields["request-body-truncated"] != true ||
+		fields["request-body-size"] != int64(len(reqBody)) {
+		t.Fatalf("request body should be truncated: %v", fields)
+	}
+	if fields["response-body"] != "héll" || fields["response-body-truncated"] != true ||
+		fields["response-body-size"] != int64(len("héllo wörld")) {
+		t.Fatalf("response body should be truncated at a rune boundary: %v", fields)
+	}
+
+	fields = observed.All()[1].ContextMap()
+	if fields["request-body"] != `{}` || fields["response-body"] != `[1]` {
+		t.Fatalf("bodies within the limits should be logged whole: %v", fields)
+	}
+	if _, ok := fields["response-body-truncated"]; ok {
+		t.Fatal("response-body-truncated should be omitted within the limit")
+	}
+}
+
+// failingReader returns data, then fails with err.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestRequestBodyReadError(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true}))
+
+	var seen []string
+	var seenErrs []error
+	r.POST(testPath, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		seen, seenErrs = append(seen, string(body)), append(seenErrs, err)
+		c.Status(204)
+	})
+
+	readErr := errors.New("connection reset")
+	for _, body := range []io.Reader{
+		&failingReader{data: `{"a":`, err: readErr},
+		strings.NewReader(""),
+		nil,
+	} {
+		req := httptest.NewRequest("POST", testPath, nil)
+		if body != nil {
+			req.Body, req.ContentLength = io.NopCloser(body), -1
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if seen[0] != `{"a":` || !errors.Is(seenErrs[0], readErr) {
+		t.Fatalf("the handler should read the partial body then the error, got %q and %v", seen[0], seenErrs[0])
+	}
+
+	failed := observed.All()[0].ContextMap()
+	if failed["request-body-read-error"] != "connection reset" {
+		t.Fatalf("request-body-read-error should be set, got %v", failed)
+	}
+	if _, ok := failed["request-body"]; ok {
+		t.Fatal("a partial body should not be logged as request-body")
+	}
+
+	if body, ok := observed.All()[1].ContextMap()["request-body"]; !ok || body != "" {
+		t.Fatalf("an empty body should be logged as \"\" but was %v", body)
+	}
+	absent := observed.All()[2].ContextMap()
+	if _, ok := absent["request-body"]; ok {
+		t.Fatal("a request without body should not log request-body")
+	}
+	if _, ok := absent["request-body-read-error"]; ok {
+		t.Fatal("a request without body should not log a read error")
+	}
+}
+
+func TestRequestBodyContentLength(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true}))
+
+	var seen string
+	r.Any(testPath, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		seen = string(body)
+		c.Status(204)
+	})
+
+	// A zero Content-Length is not read, even with a body attached.
+	req := httptest.NewRequest("GET", testPath, nil)
+	req.Body = io.NopCloser(&failingReader{err: errors.New("read on a bodyless request")})
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if fields := observed.All()[0].ContextMap(); fields["request-body"] != nil || fields["request-body-read-error"] != nil {
+		t.Fatalf("a zero Content-Length body should not be captured, got %v", fields)
+	}
+
+	req = httptest.NewRequest("POST", testPath, strings.NewReader(`{"a":1}`))
+	req.ContentLength = -1
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if body := observed.All()[1].ContextMap()["request-body"]; body == nil {
+		t.Fatal("a chunked body should be captured")
+	}
+	if seen != `{"a":1}` {
+		t.Fatalf("the handler should read the chunked body, got %q", seen)
+	}
+}
+
+func TestLogRequestBodySize(t *testing.T) {
+	for _, tt := range []struct {
+		conf *Config
+		want interface{}
+	}{
+		{&Config{LogRequestBody: true, LogRequestBodySize: true}, int64(7)},
+		{&Config{LogRequestBody: true, LogRequestBodySize: true, BodyPrefixBytes: 3}, int64(7)},
+		{&Config{LogRequestBody: true, LogRequestBodySize: true, MaxRequestBodySize: 3}, nil},
+		{&Config{LogRequestBody: true}, nil},
+	} {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, tt.conf))
+		r.POST(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		req := httptest.NewRequest("POST", testPath, strings.NewReader(`{"a":1}`))
+		req.ContentLength = -1
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		entry := observed.All()[0]
+		var sizes []zapcore.Field
+		for _, f := range entry.Context {
+			if f.Key == "request-body-size" {
+				sizes = append(sizes, f)
+			}
+		}
+		if got := entry.ContextMap()["request-body-size"]; got != tt.want || len(sizes) > 1 {
+			t.Fatalf("request-body-size should be logged once as %v, got %v", tt.want, sizes)
+		}
+	}
+}
+
+func TestCountRequestBody(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{CountRequestBody: true}))
+	r.POST(testPath, func(c *gin.Context) {
+		buf := make([]byte, 3)
+		_, _ = io.ReadFull(c.Request.Body, buf)
+		c.Status(204)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", testPath, strings.NewReader("0123456789")))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+	if read := observed.All()[0].ContextMap()["request-body-read"]; read != int64(3) {
+		t.Fatalf("request-body-read should count the bytes the handler read, got %v", read)
+	}
+	if read := observed.All()[1].ContextMap()["request-body-read"]; read != int64(0) {
+		t.Fatalf("request-body-read should be 0 without a body, got %v", read)
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abc", "abc"},
+		{"h\xc3", "h"},
+		{"h\xe4\xb8", "h"},
+		{"h\xe4\xb8\x96", "h\xe4\xb8\x96"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(trimPartialRune([]byte(tt.in))); got != tt.want {
+			t.Errorf("trimPartialRune(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBodyLogWriterStreaming(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseBody: true}))
+	captured := make(chan string, 1)
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		captured <- c.Writer.(*bodyLogWriter).body.String()
+	})
+
+	received := make(chan struct{})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.WriteString("data: 1\n\n")
+		c.Writer.Flush()
+		// Only continue once the client saw the flushed event.
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Error("the flushed event never reached the client")
+		}
+		_, _ = c.Writer.WriteString("data: 2\n\n")
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	buf := make([]byte, len("data: 1\n\n"))
+	if _, err := io.ReadFull(res.Body, buf); err != nil || string(buf) != "data: 1\n\n" {
+		t.Fatalf("first event = %q, %v", buf, err)
+	}
+	close(received)
+	rest, _ := io.ReadAll(res.Body)
+	if string(rest) != "data: 2\n\n" {
+		t.Fatalf("second event = %q", rest)
+	}
+
+	if body := <-captured; body != "data: 1\n\ndata: 2\n\n" {
+		t.Fatalf("both events should be captured but got %q", body)
+	}
+	if n := observed.Len(); n != 1 {
+		t.Fatalf("expected 1 access log but got %d", n)
+	}
+}
+
+// hijackRecorder is an httptest.ResponseRecorder supporting http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.conn, bufio.NewReadWriter(bufio.NewReader(r.conn), bufio.NewWriter(r.conn)), nil
+}
+
+func TestBodyLogWriterHijack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseBody: true, EmitServerTimingHeader: true}))
+
+	var hijackErr error
+	r.GET("/ws", func(c *gin.Context) {
+		_, _ = c.Writer.WriteString(`{"before":"upgrade"}`)
+		conn, _, err := c.Writer.Hijack()
+		hijackErr = err
+		if err == nil {
+			_, _ = conn.Write([]byte("raw"))
+			conn.Close()
+		}
+	})
+
+	server, client := net.Pipe()
+	go func() { _, _ = io.Copy(io.Discard, client) }()
+	defer client.Close()
+
+	res := &hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/ws", nil)
+	r.ServeHTTP(res, req)
+	if hijackErr != nil {
+		t.Fatalf("hijacking should be delegated but failed: %v", hijackErr)
+	}
+	if _, ok := observed.All()[0].ContextMap()["response-body"]; ok {
+		t.Fatal("response-body should not be logged for hijacked connections")
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/ws", nil)
+	r.ServeHTTP(rec, req)
+	if hijackErr != errNotHijacker {
+		t.Fatalf("hijacking an unsupported writer should fail but got %v", hijackErr)
+	}
+}
+
+func BenchmarkLogResponseBody(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.DebugMode)
+
+	r := gin.New()
+	r.Use(GinzapWithConfig(zap.NewNop(), &Config{LogResponseBody: true}))
+	body := []byte(`{"items":[` + strings.Repeat(`{"id":1,"name":"item"},`, 100) + `{"id":2}]}`)
+	r.GET(testPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", body)
+	})
+
+	req := httptest.NewRequest("GET", testPath, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestTailSample(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{TailSample: true, SlowThreshold: time.Hour}))
+
+	r.POST("/ok", func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true})
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		c.JSON(500, gin.H{"ok": false})
+	})
+
+	for _, path := range []string{"/ok", "/fail"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(`{"id":1}`))
+		req.Header.Set("X-Test", "1")
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	for _, key := range []string{"request-body", "response-body", "request-headers", "response-headers"} {
+		if _, ok := fields[key]; ok {
+			t.Fatalf("%s should only be logged for troubled requests", key)
+		}
+	}
+	fields = observed.All()[1].ContextMap()
+	if fields["request-body"] != `{"id":1}` || fields["response-body"] != `{"ok":false}` {
+		t.Fatalf("bodies of failed requests should be logged, got %v and %v", fields["request-body"], fields["response-body"])
+	}
+	if h, ok := fields["request-headers"].(map[string]interface{}); !ok || h["X-Test"] == nil {
+		t.Fatalf("request headers of failed requests should be logged, got %v", fields["request-headers"])
+	}
+	if _, ok := fields["response-headers"]; !ok {
+		t.Fatal("response headers of failed requests should be logged")
+	}
+}
+
+func TestRequestBodyBytesKey(t *testing.T) {
+	type payload struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("cached before", func(t *testing.T) {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(func(c *gin.Context) {
+			var p payload
+			if err := c.ShouldBindBodyWith(&p, binding.JSON); err != nil {
+				t.Errorf("bind: %v", err)
+			}
+		})
+		r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true}))
+		r.POST(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", testPath, strings.NewReader(`{"id":1}`))
+		r.ServeHTTP(res, req)
+
+		if body := observed.All()[0].ContextMap()["request-body"]; body != `{"id":1}` {
+			t.Fatalf("the body cached by an earlier middleware should be logged but was %v", body)
+		}
+	})
+
+	t.Run("cached after", func(t *testing.T) {
+		r := gin.New()
+		logger, _ := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, &Config{LogRequestBody: true}))
+		var cached interface{}
+		var bound payload
+		r.POST(testPath, func(c *gin.Context) {
+			cached, _ = c.Get(gin.BodyBytesKey)
+			if err := c.ShouldBindBodyWith(&bound, binding.JSON); err != nil {
+				t.Errorf("bind: %v", err)
+			}
+			c.Status(204)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", testPath, strings.NewReader(`{"id":2}`))
+		r.ServeHTTP(res, req)
+
+		if b, _ := cached.([]byte); string(b) != `{"id":2}` || bound.ID != 2 {
+			t.Fatalf("the captured body should be cached for the handlers, got %q and %+v", b, bound)
+		}
+	})
+}
+
+func TestBodyPrefixBytes(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		BodyPrefixBytes: 9,
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Data(200, "application/octet-stream", []byte("héllo wörld, and more"))
+	})
+
+	for _, body := range []string{`{"id":1,"name":"bob"}`, `{"id":1}`} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", testPath, strings.NewReader(body))
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["request-body"] != `{"id":1,"...` || fields["request-body-size"] != int64(21) {
+		t.Fatalf("the request body should be cut to its prefix with its size, got %q and %v", fields["request-body"], fields["request-body-size"])
+	}
+	// "ö" straddles the 9th byte and is left out.
+	if fields["response-body"] != "héllo w..." || fields["response-body-size"] != int64(len("héllo wörld, and more")) {
+		t.Fatalf("the response prefix should end at a rune boundary, got %q and %v", fields["response-body"], fields["response-body-size"])
+	}
+	fields = observed.All()[1].ContextMap()
+	if _, ok := fields["request-body-size"]; ok || fields["request-body"] != `{"id":1}` {
+		t.Fatalf("short bodies should be logged as usual, got %v", fields)
+	}
+}
@@ -0,0 +1,44 @@
+package ginzap
+
+import "strings"
+
+// defaultBotUserAgents are case-insensitive user-agent substrings identifying
+// automated clients for Config.ClassifyBots.
+var defaultBotUserAgents = []string{
+	"bot",
+	"crawler",
+	"spider",
+	"slurp",
+	"curl",
+	"wget",
+	"python-requests",
+	"python-urllib",
+	"go-http-client",
+	"java/",
+	"okhttp",
+	"httpclient",
+	"headlesschrome",
+	"facebookexternalhit",
+}
+
+// botMatcher reports whether a user agent contains any of its substrings.
+type botMatcher []string
+
+func newBotMatcher(extra []string) botMatcher {
+	m := make(botMatcher, 0, len(defaultBotUserAgents)+len(extra))
+	m = append(m, defaultBotUserAgents...)
+	for _, s := range extra {
+		m = append(m, strings.ToLower(s))
+	}
+	return m
+}
+
+func (m botMatcher) match(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, s := range m {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBotMatcher(t *testing.T) {
+	m := newBotMatcher([]string{"InternalProbe"})
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{"curl/8.4.0", true},
+		{"Wget/1.21", true},
+		{"python-requests/2.31", true},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"internalprobe/1.0", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := m.match(tt.userAgent); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyBots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{ClassifyBots: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, ua := range []string{"curl/8.4.0", "Mozilla/5.0 Firefox/128.0"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		req.Header.Set("User-Agent", ua)
+		r.ServeHTTP(res, req)
+	}
+
+	if bot := observed.All()[0].ContextMap()["bot"]; bot != true {
+		t.Fatalf("curl should be classified as a bot but was %v", bot)
+	}
+	if bot := observed.All()[1].ContextMap()["bot"]; bot != false {
+		t.Fatalf("firefox should not be classified as a bot but was %v", bot)
+	}
+}
@@ -0,0 +1,81 @@
+package ginzap
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// caddyMessage is the message Caddy uses for access log entries.
+const caddyMessage = "handled request"
+
+// caddyFields returns the core access log fields in the layout of Caddy's
+// JSON access logs:
+//
+//	{
+//	  "request": {
+//	    "remote_ip": "...", "remote_port": "...", "client_ip": "...",
+//	    "proto": "HTTP/1.1", "method": "GET", "host": "...", "uri": "/path?query",
+//	    "headers": {"User-Agent": ["..."]}
+//	  },
+//	  "bytes_read": 0, "duration": 0.0012, "size": 42, "status": 200,
+//	  "resp_headers": {"Content-Type": ["..."]}
+//	}
+//
+// duration is in seconds. When not empty, maskedPath replaces the path in uri,
+// and the values of its query parameters named in conf.RedactBodyKeys are
+// logged as "***".
+func caddyFields(c *gin.Context, conf *Config, maskedPath string, latency time.Duration) []zapcore.Field {
+	req := c.Request
+	uri := req.RequestURI
+	if maskedPath != "" {
+		uri = maskedPath
+		if req.URL.RawQuery != "" {
+			uri += "?" + req.URL.RawQuery
+		}
+	}
+	if target, query, ok := strings.Cut(uri, "?"); ok {
+		uri = target + "?" + redactRawQuery(query, conf.RedactBodyKeys)
+	}
+	remoteIP, remotePort, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteIP = req.RemoteAddr
+	}
+	if conf.AnonymizeIP {
+		remoteIP = anonymizeIP(remoteIP)
+	}
+	// The request object is encoded when the line is written, possibly
+	// after the request, so it must not read c.
+	ip := clientIP(c, conf)
+
+	bytesRead := req.ContentLength
+	if bytesRead < 0 {
+		bytesRead = 0
+	}
+	size := c.Writer.Size()
+	if size < 0 {
+		size = 0
+	}
+
+	return []zapcore.Field{
+		zap.Object("request", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("remote_ip", remoteIP)
+			enc.AddString("remote_port", remotePort)
+			enc.AddString("client_ip", ip)
+			enc.AddString("proto", req.Proto)
+			enc.AddString("method", req.Method)
+			enc.AddString("host", req.Host)
+			enc.AddString("uri", uri)
+			return enc.AddObject("headers", headerObject(redactHeader(req.Header, conf.RedactHeaders)))
+		})),
+		zap.Int64("bytes_read", bytesRead),
+		zap.Float64("duration", latency.Seconds()),
+		zap.Int("size", size),
+		zap.Int("status", c.Writer.Status()),
+		zap.Object("resp_headers", headerObject(redactHeader(c.Writer.Header(), conf.RedactHeaders))),
+	}
+}
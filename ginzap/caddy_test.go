@@ -0,0 +1,107 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCaddyMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	var buf bytes.Buffer
+	logger := NewAccessLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+	r.Use(GinzapWithConfig(logger, &Config{CaddyMode: true, FieldNaming: FieldNamingCamel}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.String(201, "created")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", "http://example.com/test?a=1", bytes.NewBufferString("hello"))
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.RequestURI = "/test?a=1"
+	req.Header.Set("User-Agent", "caddy-test")
+	r.ServeHTTP(res, req)
+
+	var line struct {
+		Msg     string `json:"msg"`
+		Request struct {
+			RemoteIP   string              `json:"remote_ip"`
+			RemotePort string              `json:"remote_port"`
+			ClientIP   string              `json:"client_ip"`
+			Proto      string              `json:"proto"`
+			Method     string              `json:"method"`
+			Host       string              `json:"host"`
+			URI        string              `json:"uri"`
+			Headers    map[string][]string `json:"headers"`
+		} `json:"request"`
+		BytesRead   int64               `json:"bytes_read"`
+		Duration    *float64            `json:"duration"`
+		Size        int                 `json:"size"`
+		Status      int                 `json:"status"`
+		RespHeaders map[string][]string `json:"resp_headers"`
+		Path        *string             `json:"path"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+
+	if line.Msg != "handled request" {
+		t.Fatalf("msg should be handled request but was %q", line.Msg)
+	}
+	req2 := line.Request
+	if req2.RemoteIP != "10.0.0.1" || req2.RemotePort != "5555" || req2.ClientIP != "10.0.0.1" ||
+		req2.Proto != "HTTP/1.1" || req2.Method != "POST" || req2.Host != "example.com" || req2.URI != "/test?a=1" {
+		t.Fatalf("unexpected request object %+v", req2)
+	}
+	if ua := req2.Headers["User-Agent"]; len(ua) != 1 || ua[0] != "caddy-test" {
+		t.Fatalf("request headers should be nested arrays but were %v", req2.Headers)
+	}
+	if line.BytesRead != 5 || line.Size != 7 || line.Status != 201 || line.Duration == nil {
+		t.Fatalf("unexpected top-level fields %+v", line)
+	}
+	if ct := line.RespHeaders["Content-Type"]; len(ct) != 1 || ct[0] != "text/plain" {
+		t.Fatalf("resp_headers should hold the response headers but were %v", line.RespHeaders)
+	}
+	if line.Path != nil {
+		t.Fatal("standard fields should be replaced in Caddy mode")
+	}
+}
+
+func TestCaddyModeRedactsQuery(t *testing.T) {
+	r := gin.New()
+
+	var buf bytes.Buffer
+	logger := NewAccessLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+	r.Use(GinzapWithConfig(logger, &Config{CaddyMode: true, RedactBodyKeys: []string{"token"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", testPath+"?a=1&token=secret", nil)
+	r.ServeHTTP(res, req)
+
+	var line struct {
+		Request struct {
+			URI string `json:"uri"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	if want := testPath + "?a=1&token=***"; line.Request.URI != want {
+		t.Fatalf("uri should be %q but was %q", want, line.Request.URI)
+	}
+}
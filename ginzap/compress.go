@@ -0,0 +1,51 @@
+package ginzap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+// defaultDecodeLimit caps decompressed response bodies when
+// Config.MaxResponseBodySize is unlimited.
+const defaultDecodeLimit = 1 << 20
+
+// decodeBody decompresses a body sent with a gzip or deflate
+// Content-Encoding, reading at most limit bytes (defaultDecodeLimit when not
+// positive) so a small response cannot expand into a huge log line. A body
+// cut off by the capture limit decodes up to the cut. It returns false for
+// other encodings and undecodable bodies; truncated reports a body cut off
+// at limit.
+func decodeBody(encoding string, body []byte, limit int) (decoded []byte, truncated, ok bool) {
+	var r io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// RFC 9110 deflate is zlib wrapped, but some servers send raw deflate.
+		if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, false, false
+	}
+	if err != nil {
+		return nil, false, false
+	}
+	if limit <= 0 {
+		limit = defaultDecodeLimit
+	}
+	decoded, err = io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil && (!errors.Is(err, io.ErrUnexpectedEOF) || len(decoded) == 0) {
+		return nil, false, false
+	}
+	if len(decoded) > limit {
+		return decoded[:limit], true, true
+	}
+	return decoded, err != nil, true
+}
@@ -0,0 +1,89 @@
+package ginzap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func compress(t *testing.T, encoding, s string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate"} {
+		header := strings.TrimPrefix(encoding, "raw-")
+		body, truncated, ok := decodeBody(header, compress(t, encoding, `{"ok":true}`), 0)
+		if !ok || truncated || string(body) != `{"ok":true}` {
+			t.Fatalf("%s: got %q %v %v", encoding, body, truncated, ok)
+		}
+	}
+
+	bomb := compress(t, "gzip", strings.Repeat("a", 10000))
+	if body, truncated, ok := decodeBody("gzip", bomb, 100); !ok || !truncated || len(body) != 100 {
+		t.Fatalf("decoding should stop at the limit, got %d bytes %v %v", len(body), truncated, ok)
+	}
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		sb.WriteString(strconv.Itoa(i))
+	}
+	cut := compress(t, "gzip", sb.String())
+	if body, truncated, ok := decodeBody("gzip", cut[:len(cut)/2], 0); !ok || !truncated || len(body) == 0 {
+		t.Fatalf("a cut off body should decode up to the cut, got %d bytes %v %v", len(body), truncated, ok)
+	}
+
+	if _, _, ok := decodeBody("br", []byte("x"), 0); ok {
+		t.Fatal("unsupported encodings should not be decoded")
+	}
+	if _, _, ok := decodeBody("gzip", []byte(`{"ok":true}`), 0); ok {
+		t.Fatal("invalid gzip data should not be decoded")
+	}
+}
+
+func TestLogResponseBodyGzip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseBody: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "application/json", compress(t, "gzip", `{"id":1}`))
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if body := observed.All()[0].ContextMap()["response-body"]; body != `{"id":1}` {
+		t.Fatalf("the gzip response should be logged decompressed but was %v", body)
+	}
+}
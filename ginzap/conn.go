@@ -0,0 +1,45 @@
+package ginzap
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// connRequests counts the requests served on one connection.
+type connRequests struct {
+	n atomic.Int64
+}
+
+// TrackConnReuse returns an http.Server.ConnContext hook storing a
+// per-connection request counter under key, for use with
+// Config.ConnReusedContextKey:
+//
+//	srv := &http.Server{
+//		Handler:     r,
+//		ConnContext: ginzap.TrackConnReuse("conn"),
+//	}
+//	r.Use(ginzap.GinzapWithConfig(logger, &ginzap.Config{ConnReusedContextKey: "conn"}))
+//
+// net/http does not tell handlers whether a connection is new, and the
+// ConnState hook cannot reach request contexts, so the counter is attached
+// when the connection is accepted instead.
+func TrackConnReuse(key string) func(ctx context.Context, c net.Conn) context.Context {
+	return func(ctx context.Context, c net.Conn) context.Context {
+		return context.WithValue(ctx, key, &connRequests{}) //nolint:staticcheck
+	}
+}
+
+// connReused reports whether v, the value stored under
+// Config.ConnReusedContextKey, marks a reused connection. A bool is taken
+// as-is; a TrackConnReuse counter is incremented and reports reuse from the
+// second request on.
+func connReused(v interface{}) (reused, ok bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case *connRequests:
+		return v.n.Add(1) > 1, true
+	}
+	return false, false
+}
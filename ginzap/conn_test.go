@@ -0,0 +1,61 @@
+package ginzap
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConnReused(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{ConnReusedContextKey: "conn", SkipPaths: []string{"/skip"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+	r.GET("/skip", func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.ConnContext = TrackConnReuse("conn")
+	srv.Start()
+	defer srv.Close()
+
+	client := srv.Client()
+	for _, path := range []string{testPath, "/skip", testPath} {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Drain the body so the transport can reuse the connection.
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	entries := observed.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access logs but got %d", len(entries))
+	}
+	if entries[0].ContextMap()["conn-reused"] != false {
+		t.Fatalf("the first request should open a new connection: %v", entries[0].ContextMap()["conn-reused"])
+	}
+	if entries[1].ContextMap()["conn-reused"] != true {
+		t.Fatalf("later requests should reuse the connection: %v", entries[1].ContextMap()["conn-reused"])
+	}
+}
+
+func TestConnReusedBool(t *testing.T) {
+	if reused, ok := connReused(true); !reused || !ok {
+		t.Fatal("a bool value should be taken as-is")
+	}
+	if _, ok := connReused("yes"); ok {
+		t.Fatal("other values should be ignored")
+	}
+}
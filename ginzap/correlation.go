@@ -0,0 +1,42 @@
+package ginzap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const correlationIDKey = "_ginzap/correlation-id"
+
+// CorrelationID returns the correlation id resolved by a middleware
+// configured with CorrelationHeaders, or "" when there is none.
+func CorrelationID(c *gin.Context) string {
+	return c.GetString(correlationIDKey)
+}
+
+// resolveCorrelationID returns the first non-empty value among the
+// CorrelationHeaders, or a random 16 byte hex id when none is present and
+// GenerateCorrelationID is set. The id is stored for CorrelationID and,
+// with EchoCorrelationID, set on the response under the first header name.
+func resolveCorrelationID(c *gin.Context, conf *Config) string {
+	var id string
+	for _, name := range conf.CorrelationHeaders {
+		if id = c.GetHeader(name); id != "" {
+			break
+		}
+	}
+	if id == "" && conf.GenerateCorrelationID {
+		var b [16]byte
+		_, _ = rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	if id == "" {
+		return ""
+	}
+	c.Set(correlationIDKey, id)
+	if conf.EchoCorrelationID && len(conf.CorrelationHeaders) > 0 {
+		c.Header(conf.CorrelationHeaders[0], id)
+	}
+	return id
+}
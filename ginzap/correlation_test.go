@@ -0,0 +1,56 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCorrelationHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		CorrelationHeaders:    []string{"X-Correlation-Id", "X-Request-Id"},
+		EchoCorrelationID:     true,
+		GenerateCorrelationID: true,
+	}))
+
+	var seen []string
+	r.GET(testPath, func(c *gin.Context) {
+		seen = append(seen, CorrelationID(c))
+		c.Status(204)
+	})
+
+	headers := []http.Header{
+		{"X-Correlation-Id": {"corr-1"}, "X-Request-Id": {"req-1"}},
+		{"X-Request-Id": {"req-2"}},
+		{},
+	}
+	var echoed []string
+	for _, h := range headers {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		req.Header = h
+		r.ServeHTTP(res, req)
+		echoed = append(echoed, res.Header().Get("X-Correlation-Id"))
+	}
+
+	for i, want := range []string{"corr-1", "req-2", ""} {
+		id := observed.All()[i].ContextMap()["correlation-id"]
+		if want == "" {
+			if s, _ := id.(string); len(s) != 32 {
+				t.Fatalf("a correlation id should have been generated but was %v", id)
+			}
+			want = id.(string)
+		}
+		if id != want || seen[i] != want || echoed[i] != want {
+			t.Fatalf("request %d: correlation-id should be %q but was logged %v, seen %q, echoed %q", i, want, id, seen[i], echoed[i])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ginzap
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// deduper collapses identical successive log lines within a time window,
+// measured with now, Config.Clock when set.
+type deduper struct {
+	window   time.Duration
+	countKey string
+	now      func() time.Time
+
+	mu      sync.Mutex
+	key     string
+	expires time.Time
+	gen     uint64
+	repeats int
+	fields  []zapcore.Field
+	write   func([]zapcore.Field)
+}
+
+// log writes fields unless they repeat the previous key inside the window,
+// in which case they are counted and held until the next flush.
+func (d *deduper) log(key string, fields []zapcore.Field, write func([]zapcore.Field)) {
+	now := d.now()
+
+	d.mu.Lock()
+	if key == d.key && now.Before(d.expires) {
+		d.repeats++
+		d.fields, d.write = fields, write
+		d.mu.Unlock()
+		return
+	}
+	flush := d.takeLocked()
+	d.key = key
+	d.expires = now.Add(d.window)
+	d.gen++
+	gen := d.gen
+	d.mu.Unlock()
+
+	flush()
+	write(fields)
+	time.AfterFunc(d.window, func() { d.expire(gen) })
+}
+
+// expire flushes pending repeats once the window started by gen has elapsed.
+func (d *deduper) expire(gen uint64) {
+	d.mu.Lock()
+	if gen != d.gen {
+		d.mu.Unlock()
+		return
+	}
+	flush := d.takeLocked()
+	d.key = ""
+	d.mu.Unlock()
+
+	flush()
+}
+
+// takeLocked returns a function writing the pending repeat line, if any, and
+// resets the pending state. d.mu must be held.
+func (d *deduper) takeLocked() func() {
+	if d.repeats == 0 {
+		return func() {}
+	}
+	fields := append(d.fields, zap.Int(d.countKey, d.repeats))
+	write := d.write
+	d.repeats, d.fields, d.write = 0, nil, nil
+	return func() { write(fields) }
+}
@@ -0,0 +1,88 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDedupWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{DedupWindow: 100 * time.Millisecond}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/other", func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, path := range []string{testPath, testPath, testPath, "/other", "/other"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	// first /test, collapsed /test repeats, first /other
+	if len(observed.All()) != 3 {
+		t.Fatalf("Log should be 3 lines but there're %d", len(observed.All()))
+	}
+	if _, ok := observed.All()[0].ContextMap()["repeat-count"]; ok {
+		t.Fatal("the first line should not have a repeat-count")
+	}
+	if n := observed.All()[1].ContextMap()["repeat-count"]; n != int64(2) {
+		t.Fatalf("repeat-count should be 2 but was %v", n)
+	}
+	if path := observed.All()[2].ContextMap()["path"]; path != "/other" {
+		t.Fatalf("logged path should be /other but %v", path)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(observed.All()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(observed.All()) != 4 {
+		t.Fatalf("repeats should be flushed on window expiry, got %d lines", len(observed.All()))
+	}
+	line := observed.All()[3].ContextMap()
+	if line["path"] != "/other" || line["repeat-count"] != int64(1) {
+		t.Fatalf("unexpected flushed line %v", line)
+	}
+}
+
+func TestDedupWindowClock(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{DedupWindow: time.Hour, Clock: clock}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, step := range []time.Duration{0, time.Minute, 2 * time.Hour} {
+		now = now.Add(step)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+	}
+
+	// first line, then the repeat within the hour flushed by the line
+	// logged once the Clock left the window.
+	if n := observed.Len(); n != 3 {
+		t.Fatalf("Log should be 3 lines but there're %d", n)
+	}
+	if n := observed.All()[1].ContextMap()["repeat-count"]; n != int64(1) {
+		t.Fatalf("repeat-count should be 1 but was %v", n)
+	}
+	if _, ok := observed.All()[2].ContextMap()["repeat-count"]; ok {
+		t.Fatal("the line after the window should not be collapsed")
+	}
+}
@@ -0,0 +1,61 @@
+package ginzap
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// ginErrors logs c.Errors as an array of objects for Config.AggregateErrors.
+type ginErrors []*gin.Error
+
+func (errs ginErrors) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range errs {
+		if err := enc.AppendObject(ginError{e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ginError struct {
+	*gin.Error
+}
+
+func (e ginError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", e.Error.Error())
+	enc.AddString("type", errorTypeName(e.Type))
+	if e.Meta != nil {
+		return enc.AddReflected("meta", e.Meta)
+	}
+	return nil
+}
+
+// errorTypeName names the flags set in t, e.g. "bind" or "private|render".
+func errorTypeName(t gin.ErrorType) string {
+	if t == gin.ErrorTypeAny {
+		return "any"
+	}
+	var names []string
+	for _, flag := range []struct {
+		t    gin.ErrorType
+		name string
+	}{
+		{gin.ErrorTypeBind, "bind"},
+		{gin.ErrorTypeRender, "render"},
+		{gin.ErrorTypePrivate, "private"},
+		{gin.ErrorTypePublic, "public"},
+	} {
+		if t&flag.t != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	if t&^(gin.ErrorTypeBind|gin.ErrorTypeRender|gin.ErrorTypePrivate|gin.ErrorTypePublic) != 0 {
+		names = append(names, "other")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
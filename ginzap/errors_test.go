@@ -0,0 +1,101 @@
+package ginzap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestErrorTypeName(t *testing.T) {
+	for typ, want := range map[gin.ErrorType]string{
+		gin.ErrorTypeBind:                          "bind",
+		gin.ErrorTypePrivate | gin.ErrorTypeRender: "render|private",
+		gin.ErrorTypeAny:                           "any",
+		1 << 10:                                    "other",
+		0:                                          "none",
+	} {
+		if got := errorTypeName(typ); got != want {
+			t.Errorf("errorTypeName(%d) = %s, want %s", typ, got, want)
+		}
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{AggregateErrors: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		_ = c.Error(errors.New("invalid id")).SetType(gin.ErrorTypeBind).SetMeta(map[string]string{"field": "id"})
+		_ = c.Error(errors.New("db down"))
+		c.Status(500)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if len(observed.All()) != 1 {
+		t.Fatalf("Log should be 1 line but there're %d", len(observed.All()))
+	}
+	entry := observed.All()[0]
+	if entry.Level != zapcore.ErrorLevel || entry.Message != "invalid id" {
+		t.Fatalf("unexpected line %s %q", entry.Level, entry.Message)
+	}
+	errs, ok := entry.ContextMap()["errors"].([]interface{})
+	if !ok || len(errs) != 2 {
+		t.Fatalf("errors should list both errors but was %v", entry.ContextMap()["errors"])
+	}
+	first := errs[0].(map[string]interface{})
+	if first["message"] != "invalid id" || first["type"] != "bind" || first["meta"].(map[string]string)["field"] != "id" {
+		t.Fatalf("unexpected first error %v", first)
+	}
+	second := errs[1].(map[string]interface{})
+	if second["message"] != "db down" || second["type"] != "private" {
+		t.Fatalf("unexpected second error %v", second)
+	}
+	if _, ok := second["meta"]; ok {
+		t.Fatal("meta should be omitted when unset")
+	}
+}
+
+func TestErrorLevelFunc(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		ErrorLevelFunc: func(errType gin.ErrorType, status int) zapcore.Level {
+			if errType == gin.ErrorTypeBind && status < 500 {
+				return zapcore.WarnLevel
+			}
+			return zapcore.ErrorLevel
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		_ = c.Error(errors.New("bad input")).SetType(gin.ErrorTypeBind)
+		_ = c.Error(errors.New("db down"))
+		c.Status(400)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if observed.Len() != 2 {
+		t.Fatalf("each error should be logged but got %d entries", observed.Len())
+	}
+	for i, want := range []zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel} {
+		if got := observed.All()[i].Level; got != want {
+			t.Fatalf("line %d should be logged at %s but was %s", i, want, got)
+		}
+	}
+}
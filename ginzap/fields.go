@@ -0,0 +1,75 @@
+package ginzap
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const fieldsKey = "_ginzap/fields"
+
+// AddFields adds fields to the access log line of the current request, e.g.
+// a cache-hit flag computed deep inside a handler. They are logged after the
+// fields returned by Config.Context and are not renamed by FieldNaming.
+func AddFields(c *gin.Context, fields ...zapcore.Field) {
+	if len(fields) == 0 {
+		return
+	}
+	added, _ := c.Get(fieldsKey)
+	stashed, _ := added.([]zapcore.Field)
+	c.Set(fieldsKey, append(stashed, fields...))
+}
+
+// addedFields returns the fields stashed by AddFields.
+func addedFields(c *gin.Context) []zapcore.Field {
+	added, _ := c.Get(fieldsKey)
+	fields, _ := added.([]zapcore.Field)
+	return fields
+}
+
+// pooledFieldsCap is the initial capacity of pooled field slices, enough for
+// the default fields and a few options without growing.
+const pooledFieldsCap = 32
+
+// maxPooledFields is the capacity above which field slices are left to the
+// garbage collector rather than pooled.
+const maxPooledFields = 256
+
+var fieldsPool = sync.Pool{New: func() interface{} {
+	fields := make([]zapcore.Field, 0, pooledFieldsCap)
+	return &fields
+}}
+
+// copiesFields reports whether logger is done with the fields it is given
+// once Info, Error or Log returns, so they can be pooled: zap cores copy the
+// fields they keep, and a sinkLogger converts them before calling its sink.
+// Other ZapLogger implementations may keep them.
+func copiesFields(logger ZapLogger) bool {
+	switch logger.(type) {
+	case *zap.Logger, sinkLogger:
+		return true
+	}
+	return false
+}
+
+// getFields returns an empty field slice from the pool, to be returned with
+// putFields.
+func getFields() *[]zapcore.Field {
+	return fieldsPool.Get().(*[]zapcore.Field)
+}
+
+// putFields clears fields, the slice last grown from p, and returns it to the
+// pool through p. Nothing may reference fields anymore: the logger must be
+// done with them.
+func putFields(p *[]zapcore.Field, fields []zapcore.Field) {
+	if cap(fields) > maxPooledFields {
+		return
+	}
+	for i := range fields {
+		fields[i] = zapcore.Field{}
+	}
+	*p = fields[:0]
+	fieldsPool.Put(p)
+}
@@ -0,0 +1,82 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestAddFields(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{FieldNaming: FieldNamingSnake}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		AddFields(c, zap.Bool("cache-hit", true))
+		AddFields(c)
+		AddFields(c, zap.String("tenant", "acme"), zap.Int("items", 3))
+		c.Status(204)
+	})
+	r.GET("/plain", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{testPath, "/plain"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["cache-hit"] != true || fields["tenant"] != "acme" || fields["items"] != int64(3) {
+		t.Fatalf("added fields should be logged as-is, got %v", fields)
+	}
+	if _, ok := observed.All()[1].ContextMap()["cache-hit"]; ok {
+		t.Fatal("fields added to one request should not leak into another")
+	}
+}
+
+// keepingLogger is a ZapLogger keeping the fields slices it is given.
+type keepingLogger struct {
+	lines [][]zap.Field
+}
+
+func (l *keepingLogger) Info(msg string, fields ...zap.Field) {
+	l.lines = append(l.lines, fields)
+}
+
+func (l *keepingLogger) Error(msg string, fields ...zap.Field) {
+	l.lines = append(l.lines, fields)
+}
+
+func TestFieldsNotPooledForOtherLoggers(t *testing.T) {
+	r := gin.New()
+	logger := &keepingLogger{}
+	r.Use(GinzapWithConfig(logger, &Config{}))
+	r.GET("/first", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/second", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/first", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/second", nil))
+
+	var path string
+	for _, f := range logger.lines[0] {
+		if f.Key == "path" {
+			path = f.String
+		}
+	}
+	if path != "/first" {
+		t.Fatalf("the kept fields of the first line should be intact, got path %q", path)
+	}
+}
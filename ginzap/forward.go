@@ -0,0 +1,30 @@
+package ginzap
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+type forwardsKey struct{}
+
+// RecordForward counts an internal forward or redirect of the current
+// request, logged as "internal-forwards". Call it before c.Redirect or
+// engine.HandleContext. The count lives in the request context rather than
+// in c.Keys, because HandleContext resets the keys.
+func RecordForward(c *gin.Context) {
+	if n, ok := c.Request.Context().Value(forwardsKey{}).(*int); ok {
+		*n++
+		return
+	}
+	n := 1
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), forwardsKey{}, &n))
+}
+
+// internalForwards returns the number of RecordForward calls for the request.
+func internalForwards(c *gin.Context) int {
+	if n, ok := c.Request.Context().Value(forwardsKey{}).(*int); ok {
+		return *n
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecordForward(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{SkipPaths: []string{"/b", "/c"}}))
+
+	r.GET("/a", func(c *gin.Context) {
+		RecordForward(c)
+		c.Request.URL.Path = "/b"
+		r.HandleContext(c)
+	})
+	r.GET("/b", func(c *gin.Context) {
+		RecordForward(c)
+		c.Request.URL.Path = "/c"
+		r.HandleContext(c)
+	})
+	r.GET("/c", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{"/a", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	entries := observed.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access logs but got %d", len(entries))
+	}
+	if n := entries[0].ContextMap()["internal-forwards"]; n != int64(2) {
+		t.Fatalf("internal-forwards should count both hops but was %v", n)
+	}
+	if _, ok := entries[1].ContextMap()["internal-forwards"]; ok {
+		t.Fatal("internal-forwards should be omitted without forwards")
+	}
+}
@@ -0,0 +1,26 @@
+package ginzap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGoroutineID(t *testing.T) {
+	id, ok := goroutineID()
+	if !ok || id == 0 {
+		t.Fatalf("goroutine id should be parsed but got %d, %v", id, ok)
+	}
+
+	var other uint64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		other, _ = goroutineID()
+	}()
+	wg.Wait()
+
+	if other == 0 || other == id {
+		t.Fatalf("goroutines should have distinct ids but got %d and %d", id, other)
+	}
+}
@@ -0,0 +1,75 @@
+package ginzap
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// headerObject logs an http.Header as an object of string arrays with its
+// keys sorted.
+type headerObject http.Header
+
+func (h headerObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := enc.AddArray(k, stringArray(h[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type stringArray []string
+
+func (ss stringArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, s := range ss {
+		enc.AppendString(s)
+	}
+	return nil
+}
+
+// allowHeaders returns the headers of h named in allow, compared
+// case-insensitively, or h itself when allow is empty.
+func allowHeaders(h http.Header, allow []string) http.Header {
+	if len(allow) == 0 {
+		return h
+	}
+	out := make(http.Header, len(allow))
+	for k, v := range h {
+		for _, name := range allow {
+			if strings.EqualFold(k, name) {
+				out[k] = v
+				break
+			}
+		}
+	}
+	return out
+}
+
+// cookieObject logs cookies as an object mapping each name to "***", or to
+// its value for the names in allow.
+type cookieObject struct {
+	cookies []*http.Cookie
+	allow   []string
+}
+
+func (o cookieObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, cookie := range o.cookies {
+		value := redacted
+		for _, name := range o.allow {
+			if cookie.Name == name {
+				value = cookie.Value
+				break
+			}
+		}
+		enc.AddString(cookie.Name, value)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	var buf bytes.Buffer
+	logger := NewAccessLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestHeaders:  true,
+		LogResponseHeaders: true,
+		HeaderAllowlist:    []string{"accept", "authorization", "set-cookie"},
+		RedactHeaders:      []string{"Authorization"},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Writer.Header().Add("Set-Cookie", "a=1")
+		c.Writer.Header().Add("Set-Cookie", "b=2")
+		c.Header("X-Internal", "hidden")
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.Header.Set("Authorization", "Bearer t0ken")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("User-Agent", "test")
+	r.ServeHTTP(res, req)
+
+	var line struct {
+		RequestHeaders  map[string][]string `json:"request-headers"`
+		ResponseHeaders map[string][]string `json:"response-headers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+
+	wantReq := map[string][]string{
+		"Accept":        {"text/html", "application/json"},
+		"Authorization": {"***"},
+	}
+	if !reflect.DeepEqual(line.RequestHeaders, wantReq) {
+		t.Fatalf("request-headers = %v, want %v", line.RequestHeaders, wantReq)
+	}
+	wantRes := map[string][]string{"Set-Cookie": {"a=1", "b=2"}}
+	if !reflect.DeepEqual(line.ResponseHeaders, wantRes) {
+		t.Fatalf("response-headers = %v, want %v", line.ResponseHeaders, wantRes)
+	}
+}
+
+func TestLogCookies(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogCookies: true, CookieValueAllowlist: []string{"locale"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, cookie := range []string{"session=s3cr3t; locale=en-US", ""} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", testPath, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		r.ServeHTTP(res, req)
+	}
+
+	want := map[string]interface{}{"session": "***", "locale": "en-US"}
+	if got := observed.All()[0].ContextMap()["cookies"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cookies should be %v but were %v", want, got)
+	}
+	if got, ok := observed.All()[1].ContextMap()["cookies"]; ok {
+		t.Fatalf("cookies should be omitted without cookies but were %v", got)
+	}
+}
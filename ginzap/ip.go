@@ -0,0 +1,70 @@
+package ginzap
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIP returns conf.ClientIPFunc(c), or c.ClientIP() when it is unset,
+// anonymized when conf.AnonymizeIP is set.
+func clientIP(c *gin.Context, conf *Config) string {
+	ip := resolveClientIP(c, conf)
+	if conf.AnonymizeIP {
+		return anonymizeIP(ip)
+	}
+	return ip
+}
+
+func resolveClientIP(c *gin.Context, conf *Config) string {
+	if conf.ClientIPFunc != nil {
+		return conf.ClientIPFunc(c)
+	}
+	return c.ClientIP()
+}
+
+// anonymizeIP zeroes the last octet of an IPv4 address and the last 80 bits
+// of an IPv6 address. Values that are not IP addresses are returned as-is.
+func anonymizeIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// forwardedFor returns the addresses listed by the X-Forwarded-For headers of
+// h, in order, anonymized when anonymize is set.
+func forwardedFor(h http.Header, anonymize bool) []string {
+	var addrs []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(v, ",") {
+			if addr = strings.TrimSpace(addr); addr == "" {
+				continue
+			}
+			if anonymize {
+				addr = anonymizeIP(addr)
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// remoteAddr returns the request's RemoteAddr, with its host anonymized when
+// anonymize is set.
+func remoteAddr(r *http.Request, anonymize bool) string {
+	if !anonymize {
+		return r.RemoteAddr
+	}
+	host, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return anonymizeIP(r.RemoteAddr)
+	}
+	return net.JoinHostPort(anonymizeIP(host), port)
+}
@@ -0,0 +1,95 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		ip, want string
+	}{
+		{"192.168.1.42", "192.168.1.0"},
+		{"::ffff:10.0.0.7", "10.0.0.0"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", "2001:db8:85a3::"},
+		{"", ""},
+		{"not-an-ip", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := anonymizeIP(tt.ip); got != tt.want {
+			t.Errorf("anonymizeIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestClientIPFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		ClientIPFunc: func(c *gin.Context) string { return c.GetHeader("CF-Connecting-IP") },
+		AnonymizeIP:  true,
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	r.ServeHTTP(res, req)
+
+	if ip := observed.All()[0].ContextMap()["ip"]; ip != "203.0.113.0" {
+		t.Fatalf("ip should come from ClientIPFunc and be anonymized but was %v", ip)
+	}
+}
+
+func TestLogForwardedFor(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogForwardedFor: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, xff := range [][]string{{"203.0.113.9, 10.0.0.2", "10.0.0.3"}, nil} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", testPath, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		for _, v := range xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if got, want := fields["forwarded-for"], []interface{}{"203.0.113.9", "10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("forwarded-for should be %v but was %v", want, got)
+	}
+	if fields["remote-addr"] != "10.0.0.1:1234" {
+		t.Fatalf("remote-addr should be the TCP peer but was %v", fields["remote-addr"])
+	}
+	fields = observed.All()[1].ContextMap()
+	if _, ok := fields["forwarded-for"]; ok {
+		t.Fatalf("forwarded-for should be omitted without the header but was %v", fields["forwarded-for"])
+	}
+}
+
+func TestRemoteAddrAnonymized(t *testing.T) {
+	req := httptest.NewRequest("GET", testPath, nil)
+	req.RemoteAddr = "192.168.1.42:1234"
+	if got := remoteAddr(req, true); got != "192.168.1.0:1234" {
+		t.Fatalf("remoteAddr = %q, want 192.168.1.0:1234", got)
+	}
+}
@@ -0,0 +1,36 @@
+package ginzap
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// NewAccessLogger returns a *zap.Logger that writes JSON access logs to ws,
+// separately from the application logger. Any rotating writer can be used
+// without ginzap depending on it, e.g. lumberjack:
+//
+//	w := zapcore.AddSync(&lumberjack.Logger{Filename: "/var/log/app/access.log", MaxSize: 100})
+//	r.Use(ginzap.Ginzap(ginzap.NewAccessLogger(w, zapcore.InfoLevel), time.RFC3339, true))
+//
+// Entries below level are discarded. Call Sync on the returned logger before
+// exiting to flush buffered entries.
+func NewAccessLogger(ws zapcore.WriteSyncer, level zapcore.LevelEnabler) *zap.Logger {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), ws, level)
+	return zap.New(core)
+}
+
+// NewObservedLogger returns a *zap.Logger, usable as a ZapLogger, that
+// records every entry, Debug level included, in memory, and the recorded
+// logs, for asserting on the fields middleware emitted in tests:
+//
+//	logger, logs := ginzap.NewObservedLogger()
+//	r.Use(ginzap.Ginzap(logger, time.RFC3339, true))
+//	// ... serve a request ...
+//	fields := logs.All()[0].ContextMap()
+func NewObservedLogger() (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(core), logs
+}
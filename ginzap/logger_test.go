@@ -0,0 +1,126 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewAccessLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	var buf bytes.Buffer
+	logger := NewAccessLogger(zapcore.AddSync(&buf), zapcore.InfoLevel)
+	r.Use(Ginzap(logger, time.RFC3339, true))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if err := logger.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("access log should be a JSON line: %v (%q)", err, buf.String())
+	}
+	if line["path"] != testPath || line["level"] != "info" {
+		t.Fatalf("unexpected access log line %v", line)
+	}
+}
+
+func TestNewObservedLogger(t *testing.T) {
+	r := gin.New()
+
+	logger, logs := NewObservedLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestStart: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if n := logs.Len(); n != 2 {
+		t.Fatalf("the start and access lines should be recorded but got %d entries", n)
+	}
+	if logs.All()[0].Level != zapcore.DebugLevel {
+		t.Fatalf("the start line should be recorded at Debug level but was %v", logs.All()[0].Level)
+	}
+	if got := logs.All()[1].ContextMap()["status"]; got != int64(204) {
+		t.Fatalf("status should be 204 but was %v", got)
+	}
+}
+
+// levelRecorder is a ZapLogger recording the level of each call.
+type levelRecorder struct {
+	levels []zapcore.Level
+}
+
+func (l *levelRecorder) Info(msg string, fields ...zap.Field) {
+	l.levels = append(l.levels, zapcore.InfoLevel)
+}
+
+func (l *levelRecorder) Error(msg string, fields ...zap.Field) {
+	l.levels = append(l.levels, zapcore.ErrorLevel)
+}
+
+// levelLogRecorder adds Log, implementing LevelLogger.
+type levelLogRecorder struct {
+	levelRecorder
+}
+
+func (l *levelLogRecorder) Log(level zapcore.Level, msg string, fields ...zap.Field) {
+	l.levels = append(l.levels, level)
+}
+
+func TestLevelLogger(t *testing.T) {
+	serve := func(logger ZapLogger) {
+		r := gin.New()
+		r.Use(GinzapWithConfig(logger, &Config{
+			LevelFunc: func(c *gin.Context) zapcore.Level {
+				if c.Request.URL.Path == "/debug" {
+					return zapcore.DebugLevel
+				}
+				return zapcore.WarnLevel
+			},
+		}))
+		r.GET("/:path", func(c *gin.Context) {
+			c.Status(204)
+		})
+		for _, path := range []string{"/debug", "/warn"} {
+			req, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+
+	leveled := &levelLogRecorder{}
+	serve(leveled)
+	if want := []zapcore.Level{zapcore.DebugLevel, zapcore.WarnLevel}; !reflect.DeepEqual(leveled.levels, want) {
+		t.Fatalf("a LevelLogger should get the levels %v but got %v", want, leveled.levels)
+	}
+
+	plain := &levelRecorder{}
+	serve(plain)
+	if want := []zapcore.Level{zapcore.ErrorLevel, zapcore.ErrorLevel}; !reflect.DeepEqual(plain.levels, want) {
+		t.Fatalf("other loggers should fall back to %v but got %v", want, plain.levels)
+	}
+}
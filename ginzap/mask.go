@@ -0,0 +1,31 @@
+package ginzap
+
+import "strings"
+
+// newPathMasker returns the function rewriting paths before they are logged,
+// or nil when conf masks nothing. PathMaskFunc takes precedence over
+// MaskPathSegments.
+func newPathMasker(conf *Config) func(string) string {
+	if conf.PathMaskFunc != nil {
+		return conf.PathMaskFunc
+	}
+	if len(conf.MaskPathSegments) == 0 {
+		return nil
+	}
+	masked := make(map[int]bool, len(conf.MaskPathSegments))
+	for _, i := range conf.MaskPathSegments {
+		masked[i] = true
+	}
+	return func(path string) string {
+		segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		for i := range segments {
+			if masked[i] && segments[i] != "" {
+				segments[i] = "*"
+			}
+		}
+		if strings.HasPrefix(path, "/") {
+			return "/" + strings.Join(segments, "/")
+		}
+		return strings.Join(segments, "/")
+	}
+}
@@ -0,0 +1,88 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaskPathSegments(t *testing.T) {
+	mask := newPathMasker(&Config{MaskPathSegments: []int{1, 5}})
+	tests := []struct {
+		path, want string
+	}{
+		{"/reset/abc123/confirm", "/reset/*/confirm"},
+		{"/reset", "/reset"},
+		{"/reset//confirm", "/reset//confirm"},
+		{"/", "/"},
+	}
+	for _, tt := range tests {
+		if got := mask(tt.path); got != tt.want {
+			t.Errorf("mask(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if newPathMasker(&Config{}) != nil {
+		t.Fatal("no masker should be built without masking options")
+	}
+}
+
+func TestPathMaskFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		MaskPathSegments: []int{0},
+		PathMaskFunc: func(path string) string {
+			return strings.Replace(path, "secret", "*", 1)
+		},
+	}))
+
+	r.GET("/reset/:token/confirm", func(c *gin.Context) {
+		if c.Param("token") != "secret" || c.Request.URL.Path != "/reset/secret/confirm" {
+			t.Error("masking should not affect routing")
+		}
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/reset/secret/confirm", nil)
+	r.ServeHTTP(res, req)
+
+	if path := observed.All()[0].ContextMap()["path"]; path != "/reset/*/confirm" {
+		t.Fatalf("PathMaskFunc should take precedence and mask the path, got %v", path)
+	}
+}
+
+func TestUseFullPath(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{UseFullPath: true, MaskPathSegments: []int{1}}))
+
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{"/users/12345", "/missing/secret"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["route"] != "/users/:id" || fields["path"] != "/users/*" {
+		t.Fatalf("route should be the template next to the path: %v", fields)
+	}
+	if route := observed.All()[1].ContextMap()["route"]; route != "/missing/*" {
+		t.Fatalf("unmatched requests should fall back to the logged path but route was %v", route)
+	}
+}
@@ -0,0 +1,87 @@
+package ginzap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// multipartBoundary returns the boundary of a multipart/form-data request,
+// or "" for other requests.
+func multipartBoundary(r *http.Request) string {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return ""
+	}
+	return params["boundary"]
+}
+
+// multipartPart describes one part of a multipart form, without its content.
+type multipartPart struct {
+	name, filename, contentType string
+	size                        int64
+}
+
+func (p multipartPart) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", p.name)
+	if p.filename != "" {
+		enc.AddString("filename", p.filename)
+	}
+	if p.contentType != "" {
+		enc.AddString("content-type", p.contentType)
+	}
+	enc.AddInt64("size", p.size)
+	return nil
+}
+
+type multipartParts []multipartPart
+
+func (parts multipartParts) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, p := range parts {
+		if err := enc.AppendObject(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// multipartFields returns "multipart-parts", the name, file name, content
+// type and size of every part of a captured multipart/form-data body. When
+// the body was truncated or is malformed, the parts read so far are logged
+// with "multipart-truncated": true; the last part may then be incomplete.
+func multipartFields(boundary string, body []byte, truncated bool) []zapcore.Field {
+	var parts multipartParts
+	r := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		p, err := r.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			truncated = true
+			break
+		}
+		size, err := io.Copy(io.Discard, p)
+		parts = append(parts, multipartPart{
+			name:        p.FormName(),
+			filename:    p.FileName(),
+			contentType: p.Header.Get("Content-Type"),
+			size:        size,
+		})
+		if err != nil {
+			truncated = true
+			break
+		}
+	}
+	fields := []zapcore.Field{zap.Array("multipart-parts", parts)}
+	if truncated {
+		fields = append(fields, zap.Bool("multipart-truncated", true))
+	}
+	return fields
+}
@@ -0,0 +1,111 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func multipartBody(t *testing.T) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("title", "holiday"); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := w.CreateFormFile("photo", "beach.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(bytes.Repeat([]byte{0xff}, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, w.FormDataContentType()
+}
+
+func TestLogMultipartMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogMultipartMetadata: true, LogRequestBody: true}))
+
+	var title string
+	var size int64
+	r.POST(testPath, func(c *gin.Context) {
+		title = c.PostForm("title")
+		if fh, err := c.FormFile("photo"); err == nil {
+			size = fh.Size
+		}
+		c.Status(204)
+	})
+
+	body, contentType := multipartBody(t)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, body)
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(res, req)
+
+	if title != "holiday" || size != 1000 {
+		t.Fatalf("the handler should still read the form, got %q and %d bytes", title, size)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if _, ok := fields["request-body"]; ok {
+		t.Fatal("the raw multipart body should not be logged")
+	}
+	parts, ok := fields["multipart-parts"].([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("multipart-parts should list 2 parts but was %v", fields["multipart-parts"])
+	}
+	field := parts[0].(map[string]interface{})
+	if field["name"] != "title" || field["size"] != int64(7) {
+		t.Fatalf("unexpected field part %v", field)
+	}
+	file := parts[1].(map[string]interface{})
+	if file["name"] != "photo" || file["filename"] != "beach.jpg" || file["content-type"] != "application/octet-stream" || file["size"] != int64(1000) {
+		t.Fatalf("unexpected file part %v", file)
+	}
+}
+
+func TestLogMultipartMetadataTruncated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogMultipartMetadata: true, MaxRequestBodySize: 300}))
+
+	var size int64
+	r.POST(testPath, func(c *gin.Context) {
+		if fh, err := c.FormFile("photo"); err == nil {
+			size = fh.Size
+		}
+		c.Status(204)
+	})
+
+	body, contentType := multipartBody(t)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, body)
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(res, req)
+
+	if size != 1000 {
+		t.Fatalf("the handler should still read the whole file, got %d bytes", size)
+	}
+	fields := observed.All()[0].ContextMap()
+	if fields["multipart-truncated"] != true {
+		t.Fatalf("multipart-truncated should be set, got %v", fields)
+	}
+	if parts, _ := fields["multipart-parts"].([]interface{}); len(parts) == 0 {
+		t.Fatalf("the parts read before the limit should be logged, got %v", fields["multipart-parts"])
+	}
+}
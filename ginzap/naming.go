@@ -0,0 +1,97 @@
+package ginzap
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldNaming selects the casing convention of the built-in field names.
+type FieldNaming string
+
+const (
+	// FieldNamingDefault keeps the historical names such as "user-agent".
+	FieldNamingDefault FieldNaming = ""
+	// FieldNamingKebab names fields like "user-agent".
+	FieldNamingKebab FieldNaming = "kebab"
+	// FieldNamingSnake names fields like "user_agent".
+	FieldNamingSnake FieldNaming = "snake"
+	// FieldNamingCamel names fields like "userAgent".
+	FieldNamingCamel FieldNaming = "camel"
+)
+
+// fieldNamer renames built-in field keys. A nil *fieldNamer keeps keys as-is.
+type fieldNamer struct {
+	// keys are explicit renames, taking precedence over convert.
+	keys    map[string]string
+	convert func(words []string) string
+	cache   sync.Map
+}
+
+func newFieldNamer(naming FieldNaming, keys map[string]string) *fieldNamer {
+	var n *fieldNamer
+	switch naming {
+	case FieldNamingKebab:
+		n = &fieldNamer{convert: func(words []string) string { return strings.Join(words, "-") }}
+	case FieldNamingSnake:
+		n = &fieldNamer{convert: func(words []string) string { return strings.Join(words, "_") }}
+	case FieldNamingCamel:
+		n = &fieldNamer{convert: func(words []string) string {
+			for i := 1; i < len(words); i++ {
+				words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+			}
+			return strings.Join(words, "")
+		}}
+	}
+	if len(keys) > 0 {
+		if n == nil {
+			n = &fieldNamer{}
+		}
+		n.keys = keys
+	}
+	return n
+}
+
+// key returns the name to log in place of the built-in key k.
+func (n *fieldNamer) key(k string) string {
+	if n == nil {
+		return k
+	}
+	if v, ok := n.keys[k]; ok {
+		return v
+	}
+	if n.convert == nil {
+		return k
+	}
+	if v, ok := n.cache.Load(k); ok {
+		return v.(string)
+	}
+	words := strings.FieldsFunc(strings.ToLower(k), func(r rune) bool { return r == '-' || r == '_' })
+	name := n.convert(words)
+	n.cache.Store(k, name)
+	return name
+}
+
+// rename applies key to every field in place.
+func (n *fieldNamer) rename(fields []zapcore.Field) {
+	if n == nil {
+		return
+	}
+	for i := range fields {
+		fields[i].Key = n.key(fields[i].Key)
+	}
+}
+
+// orderFields stably sorts fields by their rank; unranked fields keep their
+// relative order after all ranked ones.
+func orderFields(fields []zapcore.Field, rank map[string]int) {
+	rankOf := func(f zapcore.Field) int {
+		if r, ok := rank[f.Key]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return rankOf(fields[i]) < rankOf(fields[j]) })
+}
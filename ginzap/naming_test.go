@@ -0,0 +1,146 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFieldNamerKey(t *testing.T) {
+	tests := []struct {
+		naming FieldNaming
+		key    string
+		want   string
+	}{
+		{FieldNamingDefault, "user-agent", "user-agent"},
+		{FieldNamingKebab, "user-agent", "user-agent"},
+		{FieldNamingKebab, "status", "status"},
+		{FieldNamingSnake, "user-agent", "user_agent"},
+		{FieldNamingSnake, "query-param-count", "query_param_count"},
+		{FieldNamingCamel, "user-agent", "userAgent"},
+		{FieldNamingCamel, "query-param-count", "queryParamCount"},
+		{FieldNamingCamel, "status", "status"},
+	}
+	for _, tt := range tests {
+		if got := newFieldNamer(tt.naming, nil).key(tt.key); got != tt.want {
+			t.Errorf("%q naming of %s = %s, want %s", tt.naming, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFieldNaming(t *testing.T) {
+	tests := []struct {
+		naming FieldNaming
+		want   []string
+	}{
+		{FieldNamingKebab, []string{"status", "method", "path", "query", "ip", "user-agent", "latency", "latency-us", "custom-key"}},
+		{FieldNamingSnake, []string{"status", "method", "path", "query", "ip", "user_agent", "latency", "latency_us", "custom-key"}},
+		{FieldNamingCamel, []string{"status", "method", "path", "query", "ip", "userAgent", "latency", "latencyUs", "custom-key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.naming), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := gin.New()
+
+			logger, observed := buildDummyLogger()
+			r.Use(GinzapWithConfig(logger, &Config{
+				FieldNaming:      tt.naming,
+				LogLatencyMicros: true,
+				Context: func(c *gin.Context) []zapcore.Field {
+					return []zapcore.Field{zap.String("custom-key", "kept")}
+				},
+			}))
+
+			r.GET(testPath, func(c *gin.Context) {
+				c.JSON(204, nil)
+			})
+
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+			r.ServeHTTP(res, req)
+
+			fields := observed.All()[0].Context
+			if len(fields) != len(tt.want) {
+				t.Fatalf("expected %d fields but got %d", len(tt.want), len(fields))
+			}
+			for i, key := range tt.want {
+				if fields[i].Key != key {
+					t.Fatalf("field %d should be %s but was %s", i, key, fields[i].Key)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		FieldOrder: []string{"path", "tenant", "status"},
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("tenant", "acme")}
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	want := []string{"path", "tenant", "status", "method", "query", "ip", "user-agent", "latency"}
+	fields := observed.All()[0].Context
+	if len(fields) != len(want) {
+		t.Fatalf("expected %d fields but got %d", len(want), len(fields))
+	}
+	for i, key := range want {
+		if fields[i].Key != key {
+			t.Fatalf("field %d should be %s but was %s", i, key, fields[i].Key)
+		}
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		TimeFormat:  time.RFC3339,
+		FieldNaming: FieldNamingSnake,
+		FieldKeys:   map[string]string{"status": "http.status_code", "method": "http.method", "time": "@timestamp"},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	want := []string{"http.status_code", "http.method", "path", "query", "ip", "user_agent", "latency", "@timestamp"}
+	fields := observed.All()[0].Context
+	if len(fields) != len(want) {
+		t.Fatalf("expected %d fields but got %d", len(want), len(fields))
+	}
+	for i, key := range want {
+		if fields[i].Key != key {
+			t.Fatalf("field %d should be %s but was %s", i, key, fields[i].Key)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ginzap
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nonceSource generates "log-nonce" values: a per-middleware sequence number
+// followed by 8 random bytes, e.g. "42-9f86d081884c7d65".
+type nonceSource struct {
+	seq atomic.Uint64
+}
+
+func (n *nonceSource) next() zapcore.Field {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return zap.String("log-nonce", strconv.FormatUint(n.seq.Add(1), 10)+"-"+hex.EncodeToString(b[:]))
+}
+
+// logChain links log lines into an HMAC chain, see Config.LogChainSecret.
+type logChain struct {
+	secret []byte
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+func (l *logChain) next() []zapcore.Field {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	l.prev = LogChainMAC(l.secret, l.seq, l.prev)
+	return []zapcore.Field{zap.Uint64("log-seq", l.seq), zap.String("log-chain", l.prev)}
+}
+
+// LogChainMAC returns the "log-chain" value of the line with sequence number
+// seq, given the "log-chain" value of the line before it ("" for the first
+// line): the hex-encoded HMAC-SHA256, keyed by secret, of prev followed by
+// seq as 8 big-endian bytes. Verifiers recompute it line by line, in log-seq
+// order, to detect missing, reordered or forged lines.
+func LogChainMAC(secret []byte, seq uint64, prev string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(prev))
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	mac.Write(b[:])
+	return hex.EncodeToString(mac.Sum(nil))
+}
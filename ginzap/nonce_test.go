@@ -0,0 +1,91 @@
+package ginzap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLogNonce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogNonce: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for i := 0; i < 3; i++ {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	seen := make(map[string]bool)
+	for i, entry := range observed.All() {
+		nonce := entry.ContextMap()["log-nonce"].(string)
+		seq, random, ok := strings.Cut(nonce, "-")
+		if !ok || seq != strconv.Itoa(i+1) || len(random) != 16 || seen[random] {
+			t.Fatalf("unexpected nonce %q for line %d", nonce, i)
+		}
+		seen[random] = true
+	}
+}
+
+func TestLogChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	secret := []byte("s3cret")
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogChainSecret: secret}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("first"))
+		_ = c.Error(errors.New("second"))
+		c.Status(500)
+	})
+
+	for _, path := range []string{testPath, "/fail", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	entries := observed.All()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 lines but got %d", len(entries))
+	}
+	var prev string
+	for i, entry := range entries {
+		fields := entry.ContextMap()
+		seq := fields["log-seq"].(uint64)
+		if seq != uint64(i+1) {
+			t.Fatalf("line %d should have log-seq %d but had %d", i, i+1, seq)
+		}
+		if fields["log-chain"] != LogChainMAC(secret, seq, prev) {
+			t.Fatalf("line %d breaks the chain", i)
+		}
+		prev = fields["log-chain"].(string)
+	}
+
+	if LogChainMAC(secret, 2, "") == entries[1].ContextMap()["log-chain"] {
+		t.Fatal("dropping a line should break the chain")
+	}
+	if LogChainMAC([]byte("other"), 1, "") == entries[0].ContextMap()["log-chain"] {
+		t.Fatal("the chain should depend on the secret")
+	}
+}
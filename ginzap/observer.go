@@ -0,0 +1,17 @@
+package ginzap
+
+import "time"
+
+// Observer receives the measurements of every logged request, e.g. to update
+// Prometheus counters and latency histograms without a second middleware.
+// route is the matched route template, or "" when no route matched.
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	Observe(method, route string, status int, latency time.Duration)
+}
+
+// NopObserver is an Observer that does nothing.
+type NopObserver struct{}
+
+// Observe implements Observer.
+func (NopObserver) Observe(method, route string, status int, latency time.Duration) {}
@@ -0,0 +1,61 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type observation struct {
+	method, route string
+	status        int
+}
+
+type recordingObserver struct {
+	mu  sync.Mutex
+	obs []observation
+}
+
+func (o *recordingObserver) Observe(method, route string, status int, latency time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.obs = append(o.obs, observation{method, route, status})
+}
+
+func TestObserver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, _ := buildDummyLogger()
+	o := &recordingObserver{}
+	r.Use(GinzapWithConfig(logger, &Config{Observer: o, SkipPaths: []string{"/skipped"}}))
+
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/skipped", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{"/users/1", "/skipped", "/missing"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	want := []observation{{"GET", "/users/:id", 204}, {"GET", "", 404}}
+	if len(o.obs) != len(want) {
+		t.Fatalf("expected %d observations but got %v", len(want), o.obs)
+	}
+	for i := range want {
+		if o.obs[i] != want[i] {
+			t.Fatalf("observation %d should be %v but was %v", i, want[i], o.obs[i])
+		}
+	}
+}
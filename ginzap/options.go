@@ -0,0 +1,126 @@
+package ginzap
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option configures the middleware returned by New.
+type Option func(*Config)
+
+// New returns a gin.HandlerFunc (middleware) that logs requests using
+// uber-go/zap, configured by opts. It builds a Config and behaves exactly like
+// GinzapWithConfig with it; unlike a Config literal, new settings never break
+// existing callers, so it is the preferred constructor for new code.
+func New(logger ZapLogger, opts ...Option) gin.HandlerFunc {
+	conf := &Config{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	return GinzapWithConfig(logger, conf)
+}
+
+// WithTimeFormat sets Config.TimeFormat.
+func WithTimeFormat(layout string) Option {
+	return func(c *Config) { c.TimeFormat = layout }
+}
+
+// WithUTC sets Config.UTC.
+func WithUTC(utc bool) Option {
+	return func(c *Config) { c.UTC = utc }
+}
+
+// WithSkipPaths adds to Config.SkipPaths.
+func WithSkipPaths(paths ...string) Option {
+	return func(c *Config) { c.SkipPaths = append(c.SkipPaths, paths...) }
+}
+
+// WithSkipPathRegexps adds to Config.SkipPathRegexps.
+func WithSkipPathRegexps(regexps ...*regexp.Regexp) Option {
+	return func(c *Config) { c.SkipPathRegexps = append(c.SkipPathRegexps, regexps...) }
+}
+
+// WithSkipper sets Config.Skipper.
+func WithSkipper(skipper Skipper) Option {
+	return func(c *Config) { c.Skipper = skipper }
+}
+
+// WithContext sets Config.Context.
+func WithContext(fn Fn) Option {
+	return func(c *Config) { c.Context = fn }
+}
+
+// WithRequestBody sets Config.LogRequestBody.
+func WithRequestBody(enabled bool) Option {
+	return func(c *Config) { c.LogRequestBody = enabled }
+}
+
+// WithResponseBody sets Config.LogResponseBody.
+func WithResponseBody(enabled bool) Option {
+	return func(c *Config) { c.LogResponseBody = enabled }
+}
+
+// WithDefaultLevel sets Config.DefaultLevel.
+func WithDefaultLevel(level zapcore.Level) Option {
+	return func(c *Config) { c.DefaultLevel = level }
+}
+
+// Clone returns a copy of conf that can be changed without affecting conf:
+// its slices and maps are copied too. Functions, regular expressions and
+// shared components such as BodyBatcher and Async are kept as-is.
+func (conf *Config) Clone() *Config {
+	c := *conf
+	c.SkipPaths = cloneSlice(conf.SkipPaths)
+	c.SkipPathRegexps = cloneSlice(conf.SkipPathRegexps)
+	c.SkipMethods = cloneSlice(conf.SkipMethods)
+	c.SkipStatusCodes = cloneSlice(conf.SkipStatusCodes)
+	c.BodyContentTypes = cloneSlice(conf.BodyContentTypes)
+	c.ResponseBodyContentTypes = cloneSlice(conf.ResponseBodyContentTypes)
+	c.MaskPathSegments = cloneSlice(conf.MaskPathSegments)
+	c.RedactBodyKeys = cloneSlice(conf.RedactBodyKeys)
+	c.RedactHeaders = cloneSlice(conf.RedactHeaders)
+	c.HeaderAllowlist = cloneSlice(conf.HeaderAllowlist)
+	c.RequestHashFields = cloneSlice(conf.RequestHashFields)
+	c.BotUserAgents = cloneSlice(conf.BotUserAgents)
+	c.CorrelationHeaders = cloneSlice(conf.CorrelationHeaders)
+	c.BodyOnStatusCodes = cloneSlice(conf.BodyOnStatusCodes)
+	c.FieldOrder = cloneSlice(conf.FieldOrder)
+	c.LatencyBuckets = cloneSlice(conf.LatencyBuckets)
+	c.CookieValueAllowlist = cloneSlice(conf.CookieValueAllowlist)
+	c.LogChainSecret = cloneSlice(conf.LogChainSecret)
+	c.FieldKeys = cloneMap(conf.FieldKeys)
+	c.TimeFormats = cloneMap(conf.TimeFormats)
+	return &c
+}
+
+// With returns a Clone of conf with opts applied, to derive the config of a
+// route group from a shared one:
+//
+//	admin.Use(ginzap.GinzapWithConfig(logger, base.With(ginzap.WithSkipPaths("/admin/ping"))))
+func (conf *Config) With(opts ...Option) *Config {
+	c := conf.Clone()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
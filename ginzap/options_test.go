@@ -0,0 +1,116 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewOptions(t *testing.T) {
+	conf := &Config{}
+	for _, opt := range []Option{
+		WithTimeFormat(time.RFC3339),
+		WithUTC(true),
+		WithSkipPaths("/a"),
+		WithSkipPaths("/b"),
+		WithRequestBody(true),
+		WithResponseBody(true),
+		WithDefaultLevel(zapcore.WarnLevel),
+	} {
+		opt(conf)
+	}
+	if conf.TimeFormat != time.RFC3339 || !conf.UTC || strings.Join(conf.SkipPaths, ",") != "/a,/b" ||
+		!conf.LogRequestBody || !conf.LogResponseBody || conf.DefaultLevel != zapcore.WarnLevel {
+		t.Fatalf("options were not applied: %+v", conf)
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(New(logger,
+		WithSkipPaths("/skip"),
+		WithSkipper(func(c *gin.Context) bool { return c.Request.Method == http.MethodHead }),
+		WithContext(func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("custom", "yes")}
+		}),
+		WithDefaultLevel(zapcore.WarnLevel),
+	))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/skip", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.HEAD(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, tt := range []struct{ method, path string }{{"GET", testPath}, {"GET", "/skip"}, {"HEAD", testPath}} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, tt.method, tt.path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if observed.Len() != 1 {
+		t.Fatalf("skipped requests should not be logged, got %d lines", observed.Len())
+	}
+	entry := observed.All()[0]
+	if entry.Level != zapcore.WarnLevel || entry.ContextMap()["custom"] != "yes" {
+		t.Fatalf("unexpected entry %v %v", entry.Level, entry.ContextMap())
+	}
+}
+
+func TestConfigClone(t *testing.T) {
+	conf := &Config{}
+	v := reflect.ValueOf(conf).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+		}
+	}
+
+	clone := reflect.ValueOf(conf.Clone()).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Slice, reflect.Map:
+			if f.Pointer() == clone.Field(i).Pointer() {
+				t.Errorf("Clone should copy %s", v.Type().Field(i).Name)
+			}
+		}
+	}
+}
+
+func TestConfigWith(t *testing.T) {
+	base := &Config{SkipPaths: make([]string, 1, 4), FieldKeys: map[string]string{"ip": "client-ip"}}
+	base.SkipPaths[0] = "/healthz"
+
+	admin := base.With(WithSkipPaths("/admin/ping"), WithUTC(true))
+	admin.FieldKeys["path"] = "url"
+	public := base.With(WithSkipPaths("/robots.txt"))
+
+	if strings.Join(admin.SkipPaths, ",") != "/healthz,/admin/ping" || !admin.UTC {
+		t.Fatalf("the options should apply to the derived config: %v %v", admin.SkipPaths, admin.UTC)
+	}
+	if strings.Join(public.SkipPaths, ",") != "/healthz,/robots.txt" {
+		t.Fatalf("derived configs should not share their slices: %v", public.SkipPaths)
+	}
+	if len(base.SkipPaths) != 1 || base.UTC || len(base.FieldKeys) != 1 {
+		t.Fatalf("the base config should be left untouched: %+v", base)
+	}
+}
@@ -0,0 +1,23 @@
+package ginzap
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// OtelContext is an Fn returning "trace_id" and "span_id" from the
+// OpenTelemetry span context of the request, or nothing when the request has
+// no valid span context. The names match the OpenTelemetry log data model so
+// backends can correlate the line with its trace.
+func OtelContext(c *gin.Context) []zapcore.Field {
+	sc := trace.SpanContextFromContext(c.Request.Context())
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zapcore.Field{
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	}
+}
@@ -0,0 +1,119 @@
+package ginzap
+
+import "go.uber.org/zap/zapcore"
+
+// Profile selects a bundle of Config defaults suited to an environment.
+type Profile string
+
+const (
+	// ProfileDev logs verbosely for local development: DefaultLevel is
+	// zapcore.DebugLevel, request and response bodies and headers are logged,
+	// bodies indented with PrettyBodies, and every request is logged.
+	ProfileDev Profile = "dev"
+	// ProfileProd is the safe choice for production: UTC and AnonymizeIP are
+	// on, bodies and headers are not logged, one in ten successful requests
+	// is logged (SampleRate 0.1; errors are always logged), and the
+	// credentials headers and body keys are redacted should headers or
+	// bodies be turned on. DefaultLevel stays zapcore.InfoLevel.
+	ProfileProd Profile = "prod"
+	// ProfileTest suits tests asserting on logs: DefaultLevel is
+	// zapcore.DebugLevel, UTC is on so timestamps do not depend on the
+	// machine, LogRequestBody and LogResponseBody are on, and every request
+	// is logged.
+	ProfileTest Profile = "test"
+)
+
+// prodRedactHeaders and prodRedactBodyKeys are redacted by ProfileProd.
+var (
+	prodRedactHeaders  = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	prodRedactBodyKeys = []string{"password", "token", "secret", "access_token", "refresh_token"}
+)
+
+// NewConfig returns a Config holding the defaults of profile as plain
+// fields, to be overridden as needed before use, including back to their
+// zero value:
+//
+//	conf := ginzap.NewConfig(ginzap.ProfileDev)
+//	conf.LogResponseBody = false
+//	r.Use(ginzap.GinzapWithConfig(logger, conf))
+//
+// An unknown profile returns an empty Config.
+func NewConfig(profile Profile) *Config {
+	conf := profileDefaults(profile)
+	conf.Profile = profile
+	conf.profileApplied = true
+	return conf
+}
+
+// NewProdConfig returns NewConfig(ProfileProd).
+func NewProdConfig() *Config {
+	return NewConfig(ProfileProd)
+}
+
+// NewDevConfig returns NewConfig(ProfileDev).
+func NewDevConfig() *Config {
+	return NewConfig(ProfileDev)
+}
+
+// profileDefaults returns a new Config with the defaults of profile.
+func profileDefaults(profile Profile) *Config {
+	switch profile {
+	case ProfileDev:
+		return &Config{
+			DefaultLevel:       zapcore.DebugLevel,
+			LogRequestBody:     true,
+			LogResponseBody:    true,
+			PrettyBodies:       true,
+			LogRequestHeaders:  true,
+			LogResponseHeaders: true,
+		}
+	case ProfileProd:
+		return &Config{
+			UTC:            true,
+			AnonymizeIP:    true,
+			SampleRate:     0.1,
+			RedactHeaders:  cloneSlice(prodRedactHeaders),
+			RedactBodyKeys: cloneSlice(prodRedactBodyKeys),
+		}
+	case ProfileTest:
+		return &Config{
+			DefaultLevel:    zapcore.DebugLevel,
+			UTC:             true,
+			LogRequestBody:  true,
+			LogResponseBody: true,
+		}
+	}
+	return &Config{}
+}
+
+// withProfile returns conf with the defaults of conf.Profile filled into the
+// fields left at their zero value, for a Config literal naming a Profile.
+// A Config from NewConfig already holds them, overrides included, and is
+// returned as is.
+func (conf *Config) withProfile() *Config {
+	if conf.Profile == "" || conf.profileApplied {
+		return conf
+	}
+	d := profileDefaults(conf.Profile)
+	c := *conf
+	if c.DefaultLevel == zapcore.InfoLevel {
+		c.DefaultLevel = d.DefaultLevel
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = d.SampleRate
+	}
+	if c.RedactHeaders == nil {
+		c.RedactHeaders = d.RedactHeaders
+	}
+	if c.RedactBodyKeys == nil {
+		c.RedactBodyKeys = d.RedactBodyKeys
+	}
+	c.UTC = c.UTC || d.UTC
+	c.AnonymizeIP = c.AnonymizeIP || d.AnonymizeIP
+	c.LogRequestBody = c.LogRequestBody || d.LogRequestBody
+	c.LogResponseBody = c.LogResponseBody || d.LogResponseBody
+	c.PrettyBodies = c.PrettyBodies || d.PrettyBodies
+	c.LogRequestHeaders = c.LogRequestHeaders || d.LogRequestHeaders
+	c.LogResponseHeaders = c.LogResponseHeaders || d.LogResponseHeaders
+	return &c
+}
@@ -0,0 +1,142 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestProfileDefaults(t *testing.T) {
+	tests := []struct {
+		profile     Profile
+		level       zapcore.Level
+		bodies      bool
+		utc         bool
+		anonymizeIP bool
+		sampleRate  float64
+	}{
+		{"", zapcore.InfoLevel, false, false, false, 0},
+		{ProfileDev, zapcore.DebugLevel, true, false, false, 0},
+		{ProfileProd, zapcore.InfoLevel, false, true, true, 0.1},
+		{ProfileTest, zapcore.DebugLevel, true, true, false, 0},
+	}
+	for _, tt := range tests {
+		for _, conf := range []*Config{(&Config{Profile: tt.profile}).withProfile(), NewConfig(tt.profile)} {
+			if conf.DefaultLevel != tt.level || conf.LogRequestBody != tt.bodies || conf.LogResponseBody != tt.bodies ||
+				conf.UTC != tt.utc || conf.AnonymizeIP != tt.anonymizeIP || conf.SampleRate != tt.sampleRate {
+				t.Errorf("profile %q applied unexpected defaults: %+v", tt.profile, conf)
+			}
+		}
+	}
+}
+
+func TestProfileExplicitFieldsWin(t *testing.T) {
+	orig := &Config{Profile: ProfileProd, LogRequestBody: true}
+	conf := orig.withProfile()
+	if !conf.LogRequestBody || !conf.UTC {
+		t.Fatalf("explicit fields should be kept alongside profile defaults: %+v", conf)
+	}
+	if orig.UTC {
+		t.Fatal("withProfile should not modify the caller's Config")
+	}
+
+	conf = (&Config{Profile: ProfileDev, DefaultLevel: zapcore.WarnLevel}).withProfile()
+	if conf.DefaultLevel != zapcore.WarnLevel {
+		t.Fatalf("explicit DefaultLevel should win but got %v", conf.DefaultLevel)
+	}
+}
+
+func TestNewConfigOverrides(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	conf := NewConfig(ProfileDev)
+	conf.DefaultLevel = zapcore.InfoLevel
+	conf.LogRequestBody = false
+	r.Use(GinzapWithConfig(logger, conf))
+	r.POST(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", testPath, strings.NewReader(`{"a":1}`)))
+
+	entry := observed.All()[0]
+	if entry.Level != zapcore.InfoLevel {
+		t.Fatalf("an explicit Info level should win over the profile, got %s", entry.Level)
+	}
+	if _, ok := entry.ContextMap()["request-body"]; ok {
+		t.Fatal("body logging turned off should win over the profile")
+	}
+}
+
+func TestProfileProdAnonymizesIP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	// SampleRate 1 logs every request.
+	r.Use(GinzapWithConfig(logger, &Config{Profile: ProfileProd, SampleRate: 1}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.RemoteAddr = "203.0.113.77:4000"
+	r.ServeHTTP(res, req)
+
+	if ip := observed.All()[0].ContextMap()["ip"]; ip != "203.0.113.0" {
+		t.Fatalf("ip should be anonymized but was %v", ip)
+	}
+}
+
+func TestNewProdConfig(t *testing.T) {
+	conf := NewProdConfig()
+	if !conf.UTC || !conf.AnonymizeIP || conf.SampleRate != 0.1 || conf.Profile != ProfileProd {
+		t.Fatalf("unexpected production defaults: %+v", conf)
+	}
+	if conf.LogRequestBody || conf.LogResponseBody || conf.LogRequestHeaders || conf.LogResponseHeaders {
+		t.Fatalf("bodies and headers should not be logged in production: %+v", conf)
+	}
+	if len(conf.RedactHeaders) == 0 || len(conf.RedactBodyKeys) == 0 {
+		t.Fatalf("redaction should be on in production: %+v", conf)
+	}
+	conf.RedactHeaders[0] = "X-Changed"
+	if NewProdConfig().RedactHeaders[0] == "X-Changed" {
+		t.Fatal("NewProdConfig should return a fresh redaction list")
+	}
+
+	// Every default can be turned off again, which a Config literal cannot do.
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	conf = NewProdConfig()
+	conf.SampleRate = 0
+	conf.AnonymizeIP = false
+	r.Use(GinzapWithConfig(logger, conf))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	for i := 0; i < 20; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+	}
+	if observed.Len() != 20 {
+		t.Fatalf("every request should be logged without sampling, got %d", observed.Len())
+	}
+	if ip := observed.All()[0].ContextMap()["ip"]; ip != "192.0.2.1" {
+		t.Fatalf("the ip should not be anonymized, got %v", ip)
+	}
+}
+
+func TestNewDevConfig(t *testing.T) {
+	conf := NewDevConfig()
+	if conf.DefaultLevel != zapcore.DebugLevel || !conf.LogRequestBody || !conf.LogResponseBody || !conf.PrettyBodies ||
+		!conf.LogRequestHeaders || !conf.LogResponseHeaders || conf.SampleRate != 0 {
+		t.Fatalf("unexpected development defaults: %+v", conf)
+	}
+}
@@ -0,0 +1,117 @@
+//go:build ginzap_protobuf
+
+package ginzap
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AccessRecord holds the core access log fields, see proto/accesslog.proto.
+type AccessRecord struct {
+	Status    int
+	Method    string
+	Path      string
+	Query     string
+	IP        string
+	UserAgent string
+	Latency   time.Duration
+	Time      time.Time
+	Errors    []string
+}
+
+// AppendProto appends the protobuf encoding of r, without a length prefix,
+// to b. Fields at their zero value are omitted as in proto3.
+func (r *AccessRecord) AppendProto(b []byte) []byte {
+	if r.Status != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.Status))
+	}
+	b = appendProtoString(b, 2, r.Method)
+	b = appendProtoString(b, 3, r.Path)
+	b = appendProtoString(b, 4, r.Query)
+	b = appendProtoString(b, 5, r.IP)
+	b = appendProtoString(b, 6, r.UserAgent)
+	if r.Latency != 0 {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.Latency))
+	}
+	if !r.Time.IsZero() {
+		b = protowire.AppendTag(b, 8, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.Time.UnixNano()))
+	}
+	for _, e := range r.Errors {
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendString(b, e)
+	}
+	return b
+}
+
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// GinzapProtobuf returns a gin.HandlerFunc writing an AccessRecord for each
+// request to w, encoded as protobuf and prefixed with its varint length. It
+// is a cheaper alternative to the zap middleware for pipelines where JSON
+// encoding dominates CPU, and is only built with the ginzap_protobuf build
+// tag. Of conf, only SkipPaths, Skipper, UTC and AnonymizeIP are used; a nil
+// conf logs every request. Writes are serialized and write errors dropped.
+func GinzapProtobuf(w io.Writer, conf *Config) gin.HandlerFunc {
+	if conf == nil {
+		conf = &Config{}
+	}
+	skipPaths := make(map[string]bool, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skipPaths[path] = true
+	}
+
+	var (
+		mu       sync.Mutex
+		rec, out []byte
+	)
+
+	now := conf.clock()
+
+	return func(c *gin.Context) {
+		start := now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
+		c.Next()
+
+		if skipPaths[path] || (conf.Skipper != nil && conf.Skipper(c)) {
+			return
+		}
+		latency := now().Sub(start)
+		if conf.UTC {
+			start = start.UTC()
+		}
+		r := AccessRecord{
+			Status:    c.Writer.Status(),
+			Method:    c.Request.Method,
+			Path:      path,
+			Query:     query,
+			IP:        clientIP(c, conf),
+			UserAgent: c.Request.UserAgent(),
+			Latency:   latency,
+			Time:      start,
+			Errors:    c.Errors.Errors(),
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		rec = r.AppendProto(rec[:0])
+		out = protowire.AppendVarint(out[:0], uint64(len(rec)))
+		out = append(out, rec...)
+		_, _ = w.Write(out)
+	}
+}
@@ -0,0 +1,153 @@
+//go:build ginzap_protobuf
+
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeAccessRecord parses one length-prefixed record from b and returns it
+// with the remaining bytes.
+func decodeAccessRecord(t *testing.T, b []byte) (AccessRecord, []byte) {
+	t.Helper()
+	size, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		t.Fatal("invalid length prefix")
+	}
+	b = b[n:]
+	msg, rest := b[:size], b[size:]
+
+	var r AccessRecord
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatal("invalid tag")
+		}
+		msg = msg[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(msg)
+			msg = msg[n:]
+			switch num {
+			case 1:
+				r.Status = int(v)
+			case 7:
+				r.Latency = time.Duration(v)
+			case 8:
+				r.Time = time.Unix(0, int64(v))
+			}
+		case protowire.BytesType:
+			v, n := protowire.ConsumeString(msg)
+			msg = msg[n:]
+			switch num {
+			case 2:
+				r.Method = v
+			case 3:
+				r.Path = v
+			case 4:
+				r.Query = v
+			case 5:
+				r.IP = v
+			case 6:
+				r.UserAgent = v
+			case 9:
+				r.Errors = append(r.Errors, v)
+			}
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+	}
+	return r, rest
+}
+
+func TestGinzapProtobuf(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	var buf bytes.Buffer
+	r.Use(GinzapProtobuf(&buf, &Config{SkipPaths: []string{"/skip"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/skip", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("boom"))
+		c.Status(500)
+	})
+
+	for _, path := range []string{testPath + "?a=1", "/skip", "/fail"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		req.Header.Set("User-Agent", "proto-test")
+		r.ServeHTTP(res, req)
+	}
+
+	first, rest := decodeAccessRecord(t, buf.Bytes())
+	if first.Status != 204 || first.Method != "GET" || first.Path != testPath || first.Query != "a=1" ||
+		first.UserAgent != "proto-test" || first.Time.IsZero() {
+		t.Fatalf("unexpected record %+v", first)
+	}
+	second, rest := decodeAccessRecord(t, rest)
+	if second.Path != "/fail" || second.Status != 500 || len(second.Errors) != 1 || second.Errors[0] != "boom" {
+		t.Fatalf("unexpected record %+v", second)
+	}
+	if len(rest) != 0 {
+		t.Fatal("skipped paths should not be written")
+	}
+}
+
+func benchmarkRecord() AccessRecord {
+	return AccessRecord{
+		Status:    200,
+		Method:    "GET",
+		Path:      "/api/v1/users",
+		Query:     "page=2&limit=50",
+		IP:        "203.0.113.7",
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64)",
+		Latency:   1234567 * time.Nanosecond,
+		Time:      time.Now(),
+	}
+}
+
+func BenchmarkAccessRecordProto(b *testing.B) {
+	r := benchmarkRecord()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = r.AppendProto(buf[:0])
+	}
+}
+
+func BenchmarkAccessRecordJSON(b *testing.B) {
+	r := benchmarkRecord()
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ := enc.EncodeEntry(zapcore.Entry{}, []zapcore.Field{
+			zap.Int("status", r.Status),
+			zap.String("method", r.Method),
+			zap.String("path", r.Path),
+			zap.String("query", r.Query),
+			zap.String("ip", r.IP),
+			zap.String("user-agent", r.UserAgent),
+			zap.Duration("latency", r.Latency),
+			zap.Time("time", r.Time),
+		})
+		buf.Free()
+	}
+}
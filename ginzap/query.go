@@ -0,0 +1,104 @@
+package ginzap
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// queryObject logs query parameters as an object with sorted keys. Keys with
+// a single value map to a string, repeated keys to an array of strings.
+// Values of keys in redact, compared case-insensitively, are logged as "***".
+type queryObject struct {
+	values url.Values
+	redact []string
+}
+
+func (q queryObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(q.values))
+	for k := range q.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vs := q.values[k]
+		if q.redacts(k) {
+			vs = make([]string, len(vs))
+			for i := range vs {
+				vs[i] = redacted
+			}
+		}
+		if len(vs) == 1 {
+			enc.AddString(k, vs[0])
+			continue
+		}
+		if err := enc.AddArray(k, stringArray(vs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q queryObject) redacts(key string) bool {
+	for _, name := range q.redact {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactRawQuery returns the raw query with the values of the parameters
+// named in keys, compared case-insensitively, replaced by "***", keeping the
+// order and encoding of everything else.
+func redactRawQuery(raw string, keys []string) string {
+	if len(keys) == 0 || raw == "" {
+		return raw
+	}
+	q := queryObject{redact: keys}
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		name, _, hasValue := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if hasValue && q.redacts(name) {
+			pairs[i] = pair[:strings.IndexByte(pair, '=')+1] + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// decodeQuery returns the raw query with its names and values decoded, the
+// names sorted and the values of each name in order, or raw itself when it
+// does not parse. The values of the names in redact, compared
+// case-insensitively, are replaced by "***" either way.
+func decodeQuery(raw string, redact []string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactRawQuery(raw, redact)
+	}
+	q := queryObject{redact: redact}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(k)
+			b.WriteByte('=')
+			if q.redacts(k) {
+				v = redacted
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package ginzap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStructuredQuery(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		StructuredQuery: true,
+		RedactBodyKeys:  []string{"token"},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath+"?a=1&b=2&b=3&Token=secret", nil)
+	r.ServeHTTP(res, req)
+
+	want := map[string]interface{}{
+		"a":     "1",
+		"b":     []interface{}{"2", "3"},
+		"Token": "***",
+	}
+	if got := observed.All()[0].ContextMap()["query"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("query = %#v, want %#v", got, want)
+	}
+}
+
+func TestRawQuery(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{RedactBodyKeys: []string{"token"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath+"?a=1&b=2&b=3", nil)
+	r.ServeHTTP(res, req)
+
+	if got := observed.All()[0].ContextMap()["query"]; got != "a=1&b=2&b=3" {
+		t.Fatalf("query = %v, want the raw query string", got)
+	}
+}
+
+func TestRawQueryRedacted(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestURI: true, RedactBodyKeys: []string{"token"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath+"?a=1&token=secret&b=2", nil)
+	req.RequestURI = req.URL.RequestURI()
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if got := fields["query"]; got != "a=1&token=***&b=2" {
+		t.Fatalf("query = %v, want token redacted", got)
+	}
+	if got := fields["request-uri"]; got != testPath+"?a=1&token=***&b=2" {
+		t.Fatalf("request-uri = %v, want token redacted", got)
+	}
+}
+
+func TestOmitEmptyQuery(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{OmitEmptyQuery: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{testPath, testPath + "?a=1"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if q, ok := observed.All()[0].ContextMap()["query"]; ok {
+		t.Fatalf("an empty query should be omitted but was %q", q)
+	}
+	if q := observed.All()[1].ContextMap()["query"]; q != "a=1" {
+		t.Fatalf("query should be logged but was %v", q)
+	}
+}
+
+func TestDecodeQuery(t *testing.T) {
+	for raw, want := range map[string]string{
+		"name=John%20Doe&b=2&b=1": "b=2&b=1&name=John Doe",
+		"q=a+b&tag=%E2%9C%93":     "q=a b&tag=✓",
+		"bad=%zz":                 "bad=%zz",
+		"":                        "",
+	} {
+		if got := decodeQuery(raw, nil); got != want {
+			t.Errorf("decodeQuery(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	for raw, want := range map[string]string{
+		"Token=a%20b&x=1":      "Token=***&x=1",
+		"%74oken=secret&x=%31": "token=***&x=1",
+		"token=secret&bad=%zz": "token=***&bad=%zz",
+	} {
+		if got := decodeQuery(raw, []string{"token"}); got != want {
+			t.Errorf("decodeQuery(%q) = %q, want %q", raw, got, want)
+		}
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{DecodeQuery: true}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath+"?name=John%20Doe", nil)
+	r.ServeHTTP(res, req)
+
+	if q := observed.All()[0].ContextMap()["query"]; q != "name=John Doe" {
+		t.Fatalf("query should be decoded but was %v", q)
+	}
+}
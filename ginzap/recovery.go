@@ -0,0 +1,498 @@
+package ginzap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RecoveryConfig is config setting for RecoveryWithConfig
+type RecoveryConfig struct {
+	// Stack adds the stack trace of the panic as a "stack" field. The
+	// file:line that panicked is always logged as "panic-source".
+	Stack bool
+	// RecoveryHandler writes the response after a panic was logged.
+	// Optional, defaults to aborting with status 500.
+	RecoveryHandler gin.RecoveryFunc
+	// RePanic, instead of calling RecoveryHandler, panics again with the
+	// recovered value once it is logged, for a parent recovery middleware
+	// such as gin.Recovery or a test asserting the panic. Middlewares
+	// registered before it, the access logger included, are unwound too.
+	RePanic bool
+	// IsBrokenPipe, when set, reports broken connections in addition to the
+	// built-in check. Panics with a broken connection are logged without a
+	// stack and abort the request without calling RecoveryHandler, since
+	// nothing can be written to the client anymore.
+	IsBrokenPipe func(err interface{}) bool
+	// BrokenPipeLevel, when not nil, is the level of the lines of broken
+	// connection panics, e.g. zapcore.WarnLevel so that clients going away do
+	// not trigger error alerting. nil keeps the default of Error. Loggers not
+	// implementing LevelLogger write levels other than Info as Error.
+	BrokenPipeLevel *zapcore.Level
+	// SampleRepeats, when positive, samples panics by stack signature: the
+	// first panic with a given stack is always logged, then only one in every
+	// SampleRepeats identical ones. Every panic is still counted, and the
+	// logged lines carry the running total as "panic-count". The recovery
+	// handler runs for every panic regardless of sampling.
+	SampleRepeats int
+	// StackFilter, when set, drops the frames of the "stack" it returns
+	// false for, such as AppStackFilter, so application frames are not
+	// buried under those of gin, net/http and the runtime. Each frame is
+	// passed as its function line and its "\tfile:line" line, joined by a
+	// newline; each run of dropped frames is replaced by one
+	// "...N frames omitted" line. It requires Stack.
+	StackFilter func(frame string) bool
+	// StackFull, with StackFilter, also adds the unfiltered stack as
+	// "stack-full".
+	StackFull bool
+	// StackSampleEvery, when positive, only adds the stack to one in every
+	// StackSampleEvery panics with the same message, starting with the first,
+	// so a flood of identical panics keeps its error lines without repeating
+	// the stack; lines without it carry "stack-omitted": true. It requires
+	// Stack.
+	StackSampleEvery int
+	// SampleCacheSize bounds how many distinct stacks, or messages for
+	// StackSampleEvery, are tracked for sampling; the least recently seen
+	// are evicted first. Defaults to 1024.
+	SampleCacheSize int
+	// RedactHeaders lists headers, matched case-insensitively, whose values
+	// are replaced by "***" in the logged "request" dump.
+	RedactHeaders []string
+	// DumpRequestBody buffers the request body before the handlers run,
+	// restoring it for them, and adds it to the panic log line as
+	// "request-body": the "request" dump leaves it out, and by the time of
+	// the panic the handler has usually consumed it.
+	DumpRequestBody bool
+	// MaxRequestBodySize caps, in bytes, how much of the body DumpRequestBody
+	// buffers; 0 means unlimited. A truncated body is logged like the access
+	// logger's, with "request-body-truncated": true.
+	MaxRequestBodySize int
+	// RedactBodyKeys lists JSON keys, matched case-insensitively at any depth,
+	// whose values are replaced by "***" in the "request-body" of
+	// DumpRequestBody. Bodies that are not JSON, or truncated, are then
+	// omitted.
+	RedactBodyKeys []string
+	// LogRequestID adds "request-id" to the panic line, to join it to the
+	// access log line: the id resolved by an access logger with
+	// RequestIDHeader, or else a generated UUID, which that access logger
+	// then logs too. The access logger must be registered before the
+	// recovery middleware, as in
+	// r.Use(ginzap.GinzapWithConfig(...), ginzap.RecoveryWithConfig(...)),
+	// or both replaced by GinzapRecovery, which always correlates them.
+	LogRequestID bool
+	// Clock, when set, replaces time.Now for the "time" field. Optional.
+	Clock func() time.Time
+	// Context adds the fields it returns to the panic log line, e.g. the
+	// request id or tenant also logged by the access logger.
+	Context Fn
+}
+
+// panicKey is the context key the recovery middleware sets when it recovers
+// a panic, so that an access logger registered before it can flag the request.
+const panicKey = "_ginzap/panic"
+
+func defaultHandleRecovery(c *gin.Context, err interface{}) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+// JSONRecoveryHandler returns a gin.RecoveryFunc, for CustomRecoveryWithZap
+// or RecoveryConfig.RecoveryHandler, that aborts with status and body
+// rendered as JSON, e.g. gin.H{"error": "internal server error"}. When the
+// handler already sent the response headers it only aborts.
+func JSONRecoveryHandler(status int, body interface{}) gin.RecoveryFunc {
+	return func(c *gin.Context, err interface{}) {
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(status, body)
+	}
+}
+
+// RecoveryWithZap returns a gin.HandlerFunc (middleware)
+// that recovers from any panics and logs requests using uber-go/zap.
+// All errors are logged using zap.Error().
+// stack means whether output the stack info.
+// The stack info is easy to find where the error occurs but the stack info is too large.
+func RecoveryWithZap(logger ZapLogger, stack bool) gin.HandlerFunc {
+	return CustomRecoveryWithZap(logger, stack, defaultHandleRecovery)
+}
+
+// CustomRecoveryWithZap returns a gin.HandlerFunc (middleware) with a custom recovery handler
+// that recovers from any panics and logs requests using uber-go/zap.
+// All errors are logged using zap.Error().
+// stack means whether output the stack info.
+// The stack info is easy to find where the error occurs but the stack info is too large.
+func CustomRecoveryWithZap(logger ZapLogger, stack bool, recovery gin.RecoveryFunc) gin.HandlerFunc {
+	return RecoveryWithConfig(logger, &RecoveryConfig{Stack: stack, RecoveryHandler: recovery})
+}
+
+// RecoveryWithConfig returns a gin.HandlerFunc (middleware) using configs
+// that recovers from any panics and logs requests using uber-go/zap.
+//
+// Recovered requests are marked on the context, so when the access logger is
+// registered before the recovery middleware (r.Use(ginzap.Ginzap(...)) then
+// r.Use(ginzap.RecoveryWithZap(...))) their access log line is written at
+// Error level with a "panic": true field.
+func RecoveryWithConfig(logger ZapLogger, conf *RecoveryConfig) gin.HandlerFunc {
+	recovery := conf.RecoveryHandler
+	if recovery == nil {
+		recovery = defaultHandleRecovery
+	}
+	if conf.RePanic {
+		recovery = func(c *gin.Context, err interface{}) {
+			panic(err)
+		}
+	}
+
+	now := conf.Clock
+	if now == nil {
+		now = timeNow
+	}
+
+	var sampler *stackSampler
+	if conf.SampleRepeats > 0 {
+		sampler = newStackSampler(conf.SampleCacheSize)
+	}
+	var stackSamples *stackSampler
+	if conf.Stack && conf.StackSampleEvery > 0 {
+		stackSamples = newStackSampler(conf.SampleCacheSize)
+	}
+
+	bodyConf := &Config{RedactBodyKeys: conf.RedactBodyKeys}
+
+	return func(c *gin.Context) {
+		var requestBody []byte
+		var requestTruncated bool
+		var requestReadErr error
+		if conf.DumpRequestBody {
+			buf := getBuffer()
+			var shared bool
+			requestBody, requestTruncated, shared, requestReadErr = captureRequestBody(c, buf, conf.MaxRequestBodySize)
+			if !shared {
+				defer putBuffer(buf)
+			}
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				c.Set(panicKey, true)
+
+				// Check for a broken connection, as it is not really a
+				// condition that warrants a panic stack trace.
+				brokenPipe := isBrokenPipe(err) || (conf.IsBrokenPipe != nil && conf.IsBrokenPipe(err))
+
+				req := c.Request
+				if len(conf.RedactHeaders) > 0 {
+					r := *req
+					r.Header = redactHeader(req.Header, conf.RedactHeaders)
+					req = &r
+				}
+				httpRequest, _ := httputil.DumpRequest(req, false)
+				var requestID string
+				if conf.LogRequestID {
+					requestID = panicRequestID(c)
+				}
+				if brokenPipe {
+					fields := []zapcore.Field{
+						zap.Any("error", err),
+						zap.String("error-type", fmt.Sprintf("%T", err)),
+						zap.String("request", string(httpRequest)),
+					}
+					if requestID != "" {
+						fields = append(fields, zap.String("request-id", requestID))
+					}
+					if conf.Context != nil {
+						fields = append(fields, conf.Context(c)...)
+					}
+					level := zapcore.ErrorLevel
+					if conf.BrokenPipeLevel != nil {
+						level = *conf.BrokenPipeLevel
+					}
+					if ll, ok := logger.(LevelLogger); ok {
+						ll.Log(level, c.Request.URL.Path, fields...)
+					} else if level == zapcore.InfoLevel {
+						logger.Info(c.Request.URL.Path, fields...)
+					} else {
+						logger.Error(c.Request.URL.Path, fields...)
+					}
+					// If the connection is dead, we can't write a status to it.
+					c.Error(panicError(err)) //nolint: errcheck
+					c.Abort()
+					return
+				}
+
+				var count int
+				if sampler != nil {
+					count = sampler.observe(stackSignature())
+					if (count-1)%conf.SampleRepeats != 0 {
+						recovery(c, err)
+						return
+					}
+				}
+
+				fields := []zapcore.Field{
+					zap.Time("time", now()),
+					zap.Any("error", err),
+					zap.String("error-type", fmt.Sprintf("%T", err)),
+					zap.String("request", string(httpRequest)),
+				}
+				if e, ok := err.(error); ok {
+					if chain := errorChain(e); len(chain) > 1 {
+						fields = append(fields, zap.Strings("error-chain", chain))
+					}
+				}
+				if source, ok := panicSource(); ok {
+					fields = append(fields, zap.String("panic-source", source))
+				}
+				if sampler != nil {
+					fields = append(fields, zap.Int("panic-count", count))
+				}
+				if requestID != "" {
+					fields = append(fields, zap.String("request-id", requestID))
+				}
+				if conf.DumpRequestBody {
+					fields = append(fields, panicBodyFields(bodyConf, requestBody, requestTruncated, requestReadErr, c.Request.ContentLength)...)
+				}
+				if conf.Stack {
+					if stackSamples == nil || (stackSamples.observe(messageSignature(fmt.Sprint(err)))-1)%conf.StackSampleEvery == 0 {
+						stack := string(debug.Stack())
+						if conf.StackFilter != nil {
+							fields = append(fields, zap.String("stack", filterStack(stack, conf.StackFilter)))
+							if conf.StackFull {
+								fields = append(fields, zap.String("stack-full", stack))
+							}
+						} else {
+							fields = append(fields, zap.String("stack", stack))
+						}
+					} else {
+						fields = append(fields, zap.Bool("stack-omitted", true))
+					}
+				}
+				if conf.Context != nil {
+					fields = append(fields, conf.Context(c)...)
+				}
+				logger.Error("[Recovery from panic]", fields...)
+				recovery(c, err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// GinzapRecovery returns a single middleware logging requests like
+// GinzapWithConfig and recovering from panics like RecoveryWithConfig, in the
+// right order: a recovered request gets both the "[Recovery from panic]"
+// line, with the stack when recoveryConf.Stack is set, and its access log
+// line, at Error level with "panic": true and the status written by the
+// recovery handler, 500 by default.
+//
+// To correlate the two lines, both carry the same "request-id": the one conf
+// resolves, or else one generated when the panic is recovered. The panic line
+// also carries the "correlation-id" of the access line when conf resolves it,
+// and the fields of conf.Context when recoveryConf.Context is nil. Its request
+// dump redacts conf.RedactHeaders when recoveryConf.RedactHeaders is empty.
+// recoveryConf may be nil.
+func GinzapRecovery(logger ZapLogger, conf *Config, recoveryConf *RecoveryConfig) gin.HandlerFunc {
+	rc := RecoveryConfig{}
+	if recoveryConf != nil {
+		rc = *recoveryConf
+	}
+	names := newFieldNamer(conf.FieldNaming, conf.FieldKeys)
+	if len(rc.RedactHeaders) == 0 {
+		rc.RedactHeaders = conf.RedactHeaders
+	}
+	userContext := rc.Context
+	if userContext == nil {
+		userContext = conf.Context
+	}
+	// The wrapper below logs the request id under its FieldNaming name.
+	rc.LogRequestID = false
+	rc.Context = func(c *gin.Context) []zapcore.Field {
+		fields := []zapcore.Field{zap.String(names.key("request-id"), panicRequestID(c))}
+		if id := CorrelationID(c); id != "" {
+			fields = append(fields, zap.String(names.key("correlation-id"), id))
+		}
+		if userContext != nil {
+			fields = append(fields, userContext(c)...)
+		}
+		return fields
+	}
+	return accessLog(logger, conf, RecoveryWithConfig(logger, &rc))
+}
+
+// panicBodyFields returns the fields logging the request body buffered for
+// RecoveryConfig.DumpRequestBody, redacted with conf.RedactBodyKeys.
+func panicBodyFields(conf *Config, body []byte, truncated bool, err error, size int64) []zapcore.Field {
+	switch {
+	case err != nil:
+		return []zapcore.Field{zap.String("request-body-read-error", err.Error())}
+	case body == nil:
+		return nil
+	case truncated:
+		return truncatedBodyFields(conf, "request-body", body, size)
+	case json.Valid(body):
+		return []zapcore.Field{zap.String("request-body", string(redactBody(conf, body)))}
+	case redactsBody(conf):
+		return nil
+	}
+	return []zapcore.Field{zap.String("request-body", string(body))}
+}
+
+// panicRequestID returns the request id of c, generating and storing one for
+// RequestIDFromContext when there is none, so that an access logger
+// registered before the recovery middleware logs the same id.
+func panicRequestID(c *gin.Context) string {
+	id := RequestIDFromContext(c)
+	if id == "" {
+		id = newUUID()
+		c.Set(requestIDKey, id)
+	}
+	return id
+}
+
+// brokenPipeMessages are the lower-case fragments of the network errors
+// telling that the client went away, across Linux, macOS and Windows.
+var brokenPipeMessages = []string{
+	"broken pipe",
+	"connection reset by peer",
+	"forcibly closed by the remote host",
+	"connection was aborted",
+	"protocol wrong type for socket",
+	"use of closed network connection",
+}
+
+// isBrokenPipe reports whether the recovered value err is a *net.OpError
+// caused by a broken connection.
+func isBrokenPipe(err interface{}) bool {
+	ne, ok := err.(*net.OpError)
+	if !ok || ne.Err == nil {
+		return false
+	}
+	msg := strings.ToLower(ne.Err.Error())
+	for _, fragment := range brokenPipeMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// recoveryFuncPrefix prefixes the names of the functions of the recovery
+// middleware itself, which sit above the panic site on the stack.
+var recoveryFuncPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndexByte(name, '/')
+	return name[:slash+strings.IndexByte(name[slash:], '.')] + ".RecoveryWithConfig."
+}()
+
+// panicSource returns the "dir/file.go:line" of the frame that panicked: the
+// first frame outside the runtime and the recovery middleware. It must be
+// called from the deferred recover.
+func panicSource() (string, bool) {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") && !strings.HasPrefix(frame.Function, recoveryFuncPrefix) {
+			caller := zapcore.EntryCaller{Defined: true, File: frame.File, Line: frame.Line}
+			return caller.TrimmedPath(), true
+		}
+		if !more {
+			return "", false
+		}
+	}
+}
+
+// stackFilterPrefixes are the packages whose frames AppStackFilter drops.
+var stackFilterPrefixes = []string{"runtime.", "panic(", "runtime/debug.", "net/http.", "github.com/gin-gonic/gin.", recoveryFuncPrefix}
+
+// AppStackFilter is a RecoveryConfig.StackFilter keeping the frames of
+// application code: it drops those of the runtime, net/http, gin and the
+// recovery middleware.
+func AppStackFilter(frame string) bool {
+	for _, prefix := range stackFilterPrefixes {
+		if strings.HasPrefix(frame, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterStack returns the goroutine stack trace stack, as printed by
+// debug.Stack, without the frames keep returns false for.
+func filterStack(stack string, keep func(frame string) bool) string {
+	lines := strings.Split(strings.TrimSuffix(stack, "\n"), "\n")
+	var b strings.Builder
+	// The first line is the goroutine header, e.g. "goroutine 1 [running]:".
+	b.WriteString(lines[0])
+	b.WriteByte('\n')
+	omitted := 0
+	flush := func() {
+		if omitted > 0 {
+			fmt.Fprintf(&b, "...%d frames omitted\n", omitted)
+			omitted = 0
+		}
+	}
+	for i := 1; i < len(lines); {
+		// A frame is its function line followed by its indented location.
+		end := i + 1
+		for end < len(lines) && strings.HasPrefix(lines[end], "\t") {
+			end++
+		}
+		frame := strings.Join(lines[i:end], "\n")
+		if keep(frame) {
+			flush()
+			b.WriteString(frame)
+			b.WriteByte('\n')
+		} else {
+			omitted++
+		}
+		i = end
+	}
+	flush()
+	return b.String()
+}
+
+// panicError returns the recovered value err as an error, formatting values
+// that are not errors with %v.
+func panicError(err interface{}) error {
+	if e, ok := err.(error); ok {
+		return e
+	}
+	return fmt.Errorf("%v", err)
+}
+
+// errorChain returns the messages of err and of every error it wraps, in
+// depth-first order.
+func errorChain(err error) []string {
+	var chain []string
+	var walk func(error)
+	walk = func(e error) {
+		if e == nil {
+			return
+		}
+		chain = append(chain, e.Error())
+		switch u := e.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, inner := range u.Unwrap() {
+				walk(inner)
+			}
+		}
+	}
+	walk(err)
+	return chain
+}
@@ -0,0 +1,595 @@
+package ginzap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRecoveryErrorChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithZap(logger, false))
+
+	cause := errors.New("connection refused")
+	r.GET(testPath, func(c *gin.Context) {
+		panic(fmt.Errorf("load user: %w", fmt.Errorf("query db: %w", cause)))
+	})
+	r.GET("/plain", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	for _, path := range []string{testPath, "/plain"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("status should be 500 but was %d", res.Code)
+		}
+	}
+
+	chain, ok := observed.All()[0].ContextMap()["error-chain"].([]interface{})
+	if !ok || len(chain) != 3 || chain[2] != "connection refused" {
+		t.Fatalf("error-chain should end with the root cause but was %v", observed.All()[0].ContextMap()["error-chain"])
+	}
+
+	if _, ok := observed.All()[1].ContextMap()["error-chain"]; ok {
+		t.Fatal("error-chain should be omitted for non-error panics")
+	}
+}
+
+func TestRecoveryErrorType(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithZap(logger, false))
+
+	r.GET("/string", func(c *gin.Context) { panic("boom") })
+	r.GET("/error", func(c *gin.Context) { panic(fmt.Errorf("wrap: %w", errors.New("boom"))) })
+	r.GET("/int", func(c *gin.Context) { panic(42) })
+
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/string", "string"},
+		{"/error", "*fmt.wrapError"},
+		{"/int", "int"},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		r.ServeHTTP(res, req)
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: status should be 500 but was %d", tt.path, res.Code)
+		}
+		if got := observed.All()[len(observed.All())-1].ContextMap()["error-type"]; got != tt.want {
+			t.Fatalf("%s: error-type should be %q but was %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	cause := errors.New("boom")
+	if err := panicError(cause); err != cause {
+		t.Fatalf("errors should be returned as-is but got %v", err)
+	}
+	for _, v := range []interface{}{"boom", 42} {
+		if err := panicError(v); err == nil || err.Error() != fmt.Sprint(v) {
+			t.Fatalf("panicError(%v) = %v", v, err)
+		}
+	}
+}
+
+func TestRecoverySampleRepeats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{Stack: true, SampleRepeats: 3}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("same site")
+	})
+	r.GET("/other", func(c *gin.Context) {
+		panic("other site")
+	})
+
+	for i := 0; i < 7; i++ {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		r.ServeHTTP(res, req)
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("every panic should be recovered, got status %d", res.Code)
+		}
+	}
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/other", nil)
+	r.ServeHTTP(res, req)
+
+	// occurrences 1, 4 and 7 of /test, then the first of /other
+	lines := observed.All()
+	if len(lines) != 4 {
+		t.Fatalf("Log should be 4 lines but there're %d", len(lines))
+	}
+	for i, want := range []int64{1, 4, 7, 1} {
+		fields := lines[i].ContextMap()
+		if fields["panic-count"] != want {
+			t.Fatalf("line %d: panic-count should be %d but was %v", i, want, fields["panic-count"])
+		}
+		if _, ok := fields["stack"]; !ok {
+			t.Fatalf("line %d: sampled lines should keep the stack", i)
+		}
+	}
+}
+
+func TestRecoveryClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	frozen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{Clock: func() time.Time { return frozen }}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if ts, ok := observed.All()[0].ContextMap()["time"].(time.Time); !ok || !ts.Equal(frozen) {
+		t.Fatalf("time should come from the clock but was %v", observed.All()[0].ContextMap()["time"])
+	}
+}
+
+func TestRecoveryStackSampleEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{Stack: true, StackSampleEvery: 2}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic(c.Query("msg"))
+	})
+
+	for _, msg := range []string{"a", "a", "a", "b"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath+"?msg="+msg, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	lines := observed.All()
+	if len(lines) != 4 {
+		t.Fatalf("every panic should be logged but there're %d lines", len(lines))
+	}
+	for i, want := range []bool{true, false, true, true} {
+		fields := lines[i].ContextMap()
+		if _, ok := fields["stack"]; ok != want {
+			t.Fatalf("line %d: stack presence should be %v, got fields %v", i, want, fields)
+		}
+		if !want && fields["stack-omitted"] != true {
+			t.Fatalf("line %d should be marked stack-omitted", i)
+		}
+	}
+}
+
+func TestStackSamplerEviction(t *testing.T) {
+	s := newStackSampler(2)
+	s.observe(1)
+	s.observe(2)
+	s.observe(1)
+	s.observe(3) // evicts 2, the least recently seen
+
+	if n := s.observe(1); n != 3 {
+		t.Fatalf("signature 1 should have been kept, count %d", n)
+	}
+	if n := s.observe(2); n != 1 {
+		t.Fatalf("signature 2 should have been evicted, count %d", n)
+	}
+}
+
+func TestRecoveryMarksAccessLog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	accessLogger, accessObserved := buildDummyLogger()
+	recoveryLogger, _ := buildDummyLogger()
+	r.Use(Ginzap(accessLogger, "", false))
+	r.Use(RecoveryWithZap(recoveryLogger, false))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		c.Status(500)
+	})
+
+	for _, path := range []string{testPath, "/ok"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	panicked := accessObserved.All()[0]
+	if panicked.Level != zapcore.ErrorLevel || panicked.ContextMap()["panic"] != true {
+		t.Fatalf("recovered request should be logged at error with panic=true, got %s %v", panicked.Level, panicked.ContextMap())
+	}
+
+	plain := accessObserved.All()[1]
+	if _, ok := plain.ContextMap()["panic"]; ok || plain.Level != zapcore.InfoLevel {
+		t.Fatalf("a plain 500 should not be marked as a panic, got %s %v", plain.Level, plain.ContextMap())
+	}
+}
+
+func TestRecoveryRePanic(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{RePanic: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	defer func() {
+		if err := recover(); err != "boom" {
+			t.Fatalf("the panic should propagate but recovered %v", err)
+		}
+		if observed.Len() != 1 {
+			t.Fatalf("the panic should be logged before propagating, got %d entries", observed.Len())
+		}
+	}()
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+	t.Fatal("ServeHTTP should have panicked")
+}
+
+func TestGinzapRecovery(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapRecovery(logger, &Config{
+		RequestIDHeader: "X-Request-ID",
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("tenant", "acme")}
+		},
+	}, &RecoveryConfig{Stack: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("status should be 500 but was %d", res.Code)
+	}
+	if observed.Len() != 2 {
+		t.Fatalf("the panic and access lines should be logged but got %d entries", observed.Len())
+	}
+
+	panicked := observed.All()[0].ContextMap()
+	if _, ok := panicked["stack"]; !ok || panicked["request-id"] != "req-1" || panicked["tenant"] != "acme" {
+		t.Fatalf("the panic line should carry the stack and the access line correlation fields, got %v", panicked)
+	}
+
+	access := observed.All()[1]
+	fields := access.ContextMap()
+	if access.Level != zapcore.ErrorLevel || fields["panic"] != true || fields["status"] != int64(500) || fields["request-id"] != "req-1" {
+		t.Fatalf("the access line should be an Error with panic=true and status 500, got %s %v", access.Level, fields)
+	}
+}
+
+func TestGinzapRecoveryRedactHeaders(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapRecovery(logger, &Config{RedactHeaders: []string{"Authorization"}}, nil))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest("GET", testPath, nil)
+	req.Header.Set("Authorization", "Bearer t0ken")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	dump := observed.All()[0].ContextMap()["request"].(string)
+	if strings.Contains(dump, "t0ken") || !strings.Contains(dump, "Authorization: ***") {
+		t.Fatalf("the request dump should redact the headers of conf: %q", dump)
+	}
+}
+
+func TestRecoveryLogRequestID(t *testing.T) {
+	for _, name := range []string{"RecoveryWithConfig", "GinzapRecovery"} {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		if name == "GinzapRecovery" {
+			r.Use(GinzapRecovery(logger, &Config{}, nil))
+		} else {
+			r.Use(GinzapWithConfig(logger, &Config{}), RecoveryWithConfig(logger, &RecoveryConfig{LogRequestID: true}))
+		}
+		r.GET(testPath, func(c *gin.Context) {
+			panic("boom")
+		})
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+		if observed.Len() != 2 {
+			t.Fatalf("%s: the panic and access lines should be logged but got %d entries", name, observed.Len())
+		}
+		panicID := observed.All()[0].ContextMap()["request-id"]
+		accessID := observed.All()[1].ContextMap()["request-id"]
+		if id, ok := panicID.(string); !ok || len(id) != 36 || accessID != panicID {
+			t.Fatalf("%s: both lines should carry the same generated request-id, got %v and %v", name, panicID, accessID)
+		}
+	}
+}
+
+func TestRecoveryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("request-id", c.GetHeader("X-Request-Id"))}
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	r.ServeHTTP(res, req)
+
+	if id := observed.All()[0].ContextMap()["request-id"]; id != "req-1" {
+		t.Fatalf("the panic line should carry the context fields, got request-id %v", id)
+	}
+}
+
+func TestRecoveryDumpRequestBody(t *testing.T) {
+	tests := []struct {
+		conf *RecoveryConfig
+		body string
+		want map[string]interface{}
+	}{
+		{&RecoveryConfig{DumpRequestBody: true}, `{"a":1}`, map[string]interface{}{"request-body": `{"a":1}`}},
+		{&RecoveryConfig{DumpRequestBody: true, RedactBodyKeys: []string{"password"}}, `{"user":"u","password":"p"}`,
+			map[string]interface{}{"request-body": `{"password":"***","user":"u"}`}},
+		{&RecoveryConfig{DumpRequestBody: true, MaxRequestBodySize: 4}, "abcdefgh",
+			map[string]interface{}{"request-body": "abcd", "request-body-truncated": true, "request-body-size": int64(8)}},
+		{&RecoveryConfig{DumpRequestBody: true, RedactBodyKeys: []string{"password"}}, "password=p", map[string]interface{}{}},
+		{&RecoveryConfig{}, `{"a":1}`, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(RecoveryWithConfig(logger, tt.conf))
+
+		var seen []byte
+		r.POST(testPath, func(c *gin.Context) {
+			seen, _ = io.ReadAll(c.Request.Body)
+			panic("boom")
+		})
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", testPath, strings.NewReader(tt.body)))
+
+		if string(seen) != tt.body {
+			t.Fatalf("the handler should read the whole body, got %q", seen)
+		}
+		fields := observed.All()[0].ContextMap()
+		for _, key := range []string{"request-body", "request-body-truncated", "request-body-size"} {
+			if fields[key] != tt.want[key] {
+				t.Fatalf("%s of %q should be %v but was %v", key, tt.body, tt.want[key], fields[key])
+			}
+		}
+	}
+}
+
+func TestRecoveryPanicSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithZap(logger, false))
+
+	var line int
+	r.GET(testPath, func(c *gin.Context) {
+		_, _, line, _ = runtime.Caller(0)
+		var m map[string]int
+		m["boom"]++ // two lines below Caller
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	want := fmt.Sprintf("ginzap/recovery_test.go:%d", line+2)
+	if source := observed.All()[0].ContextMap()["panic-source"]; source != want {
+		t.Fatalf("panic-source should be %s but was %v", want, source)
+	}
+}
+
+func TestJSONRecoveryHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, _ := buildDummyLogger()
+	r.Use(CustomRecoveryWithZap(logger, false, JSONRecoveryHandler(http.StatusServiceUnavailable, gin.H{"error": "internal server error"})))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusServiceUnavailable || res.Body.String() != `{"error":"internal server error"}` {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/written", nil)
+	r.ServeHTTP(res, req)
+	if res.Code != http.StatusOK || res.Body.String() != "partial" {
+		t.Fatalf("a started response should be left alone, got %d %s", res.Code, res.Body.String())
+	}
+}
+
+func TestIsBrokenPipe(t *testing.T) {
+	opError := func(err error) *net.OpError {
+		return &net.OpError{Op: "write", Net: "tcp", Err: err}
+	}
+	for _, tt := range []struct {
+		err  interface{}
+		want bool
+	}{
+		{opError(os.NewSyscallError("write", syscall.EPIPE)), true},
+		{opError(os.NewSyscallError("write", syscall.ECONNRESET)), true},
+		{opError(os.NewSyscallError("wsasend", errors.New("An existing connection was forcibly closed by the remote host."))), true},
+		{opError(os.NewSyscallError("wsasend", errors.New("An established connection was aborted by the software in your host machine."))), true},
+		{opError(os.NewSyscallError("write", errors.New("protocol wrong type for socket"))), true},
+		{opError(net.ErrClosed), true},
+		{opError(os.NewSyscallError("write", errors.New("no buffer space available"))), false},
+		{errors.New("broken pipe"), false},
+		{"broken pipe", false},
+	} {
+		if got := isBrokenPipe(tt.err); got != tt.want {
+			t.Errorf("isBrokenPipe(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRecoveryIsBrokenPipe(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	errGone := errors.New("client gone")
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{
+		Stack:        true,
+		IsBrokenPipe: func(err interface{}) bool { return err == errGone },
+		RecoveryHandler: func(c *gin.Context, err interface{}) {
+			t.Error("the recovery handler should not run for broken connections")
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		panic(errGone)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	entry := observed.All()[0]
+	if _, ok := entry.ContextMap()["stack"]; ok || entry.Message != testPath {
+		t.Fatalf("broken connections should be logged under the path without a stack, got %q %v", entry.Message, entry.ContextMap())
+	}
+}
+
+func TestRecoveryBrokenPipeLevel(t *testing.T) {
+	info, warn := zapcore.InfoLevel, zapcore.WarnLevel
+	for _, tt := range []struct {
+		level *zapcore.Level
+		want  zapcore.Level
+	}{
+		{nil, zapcore.ErrorLevel},
+		{&info, zapcore.InfoLevel},
+		{&warn, zapcore.WarnLevel},
+	} {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(RecoveryWithConfig(logger, &RecoveryConfig{BrokenPipeLevel: tt.level}))
+		r.GET(testPath, func(c *gin.Context) {
+			panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+		})
+		r.GET("/boom", func(c *gin.Context) {
+			panic("boom")
+		})
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/boom", nil))
+
+		entries := observed.All()
+		if entries[0].Message != testPath || entries[0].Level != tt.want {
+			t.Fatalf("the broken pipe should be logged at %s, got %q at %s", tt.want, entries[0].Message, entries[0].Level)
+		}
+		if entries[1].Level != zapcore.ErrorLevel {
+			t.Fatalf("other panics should stay at Error, got %s", entries[1].Level)
+		}
+	}
+}
+
+func TestFilterStack(t *testing.T) {
+	stack := "goroutine 1 [running]:\n" +
+		"runtime/debug.Stack()\n\t/go/src/runtime/debug/stack.go:26 +0x5e\n" +
+		"main.handler(0xc000)\n\t/app/main.go:10 +0x1f\n" +
+		"github.com/gin-gonic/gin.(*Context).Next(...)\n\t/gin/context.go:174\n" +
+		"github.com/gin-gonic/gin.(*Engine).handleHTTPRequest(0xc000)\n\t/gin/gin.go:620 +0x66\n" +
+		"main.serve()\n\t/app/main.go:20 +0x2a\n"
+	want := "goroutine 1 [running]:\n" +
+		"...1 frames omitted\n" +
+		"main.handler(0xc000)\n\t/app/main.go:10 +0x1f\n" +
+		"...2 frames omitted\n" +
+		"main.serve()\n\t/app/main.go:20 +0x2a\n"
+	if got := filterStack(stack, AppStackFilter); got != want {
+		t.Fatalf("filterStack should drop the runtime and gin frames, got\n%s", got)
+	}
+}
+
+func TestRecoveryStackFilter(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{Stack: true, StackFilter: AppStackFilter, StackFull: true}))
+	r.GET(testPath, func(c *gin.Context) {
+		panic("boom")
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+	fields := observed.All()[0].ContextMap()
+	stack, full := fields["stack"].(string), fields["stack-full"].(string)
+	if !strings.Contains(stack, "TestRecoveryStackFilter") || strings.Contains(stack, "gin-gonic/gin.") || !strings.Contains(stack, "frames omitted") {
+		t.Fatalf("stack should keep the application frames only, got\n%s", stack)
+	}
+	if !strings.Contains(full, "gin-gonic/gin.") {
+		t.Fatalf("stack-full should be the whole stack, got\n%s", full)
+	}
+}
@@ -0,0 +1,117 @@
+package ginzap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// redacted replaces the values of redacted headers and body keys.
+const redacted = "***"
+
+// RedactFunc controls how captured JSON bodies are logged. It is called for
+// every object member, at any depth, with the member key and its decoded
+// value (numbers are json.Number). When it returns true the value is replaced
+// by the returned one and its children are not visited.
+type RedactFunc func(key string, value interface{}) (interface{}, bool)
+
+// redactJSON applies fn to body and returns the re-encoded document. Bodies
+// that are not valid JSON are returned unchanged.
+func redactJSON(body []byte, fn RedactFunc) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	if out, ok := encodeJSON(redactValue(v, fn)); ok {
+		return out
+	}
+	return body
+}
+
+// redactKeys returns a RedactFunc replacing the values of the listed keys,
+// compared case-insensitively, with "***", then deferring to next, when not
+// nil, for the other members.
+func redactKeys(keys []string, next RedactFunc) RedactFunc {
+	return func(key string, value interface{}) (interface{}, bool) {
+		for _, k := range keys {
+			if strings.EqualFold(k, key) {
+				return redacted, true
+			}
+		}
+		if next != nil {
+			return next(key, value)
+		}
+		return nil, false
+	}
+}
+
+// redactBody applies conf.RedactBodyKeys and conf.RedactFunc to body in a
+// single redactJSON walk, keys first.
+func redactBody(conf *Config, body []byte) []byte {
+	fn := conf.RedactFunc
+	if len(conf.RedactBodyKeys) > 0 {
+		fn = redactKeys(conf.RedactBodyKeys, fn)
+	}
+	if fn == nil {
+		return body
+	}
+	return redactJSON(body, fn)
+}
+
+// redactsBody reports whether conf rewrites captured bodies.
+func redactsBody(conf *Config) bool {
+	return conf.RedactFunc != nil || len(conf.RedactBodyKeys) > 0
+}
+
+// redactHeader returns h with the values of the named headers, compared
+// case-insensitively, replaced by "***". h itself is never modified.
+func redactHeader(h http.Header, names []string) http.Header {
+	var out http.Header
+	for k := range h {
+		for _, name := range names {
+			if strings.EqualFold(k, name) {
+				if out == nil {
+					out = h.Clone()
+				}
+				out[k] = []string{redacted}
+				break
+			}
+		}
+	}
+	if out == nil {
+		return h
+	}
+	return out
+}
+
+func encodeJSON(v interface{}) ([]byte, bool) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+func redactValue(v interface{}, fn RedactFunc) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if repl, ok := fn(k, val); ok {
+				t[k] = repl
+				continue
+			}
+			t[k] = redactValue(val, fn)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redactValue(val, fn)
+		}
+	}
+	return v
+}
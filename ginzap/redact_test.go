@@ -0,0 +1,172 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func maskCard(key string, value interface{}) (interface{}, bool) {
+	if key != "card" {
+		return nil, false
+	}
+	s, _ := value.(string)
+	if len(s) < 4 {
+		return "****", true
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:], true
+}
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"top level", `{"card":"4111111111111111","id":1}`, `{"card":"************1111","id":1}`},
+		{"nested", `{"user":{"card":"12345"}}`, `{"user":{"card":"*2345"}}`},
+		{"array", `[{"card":"ab"},{"name":"<b>"}]`, `[{"card":"****"},{"name":"<b>"}]`},
+		{"not json", `card=123`, `card=123`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactJSON([]byte(tt.body), maskCard)); got != tt.want {
+				t.Fatalf("redactJSON(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		RedactFunc:      maskCard,
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.Data(http.StatusOK, "application/json", body)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewBufferString(`{"card":"4111111111111111"}`))
+	r.ServeHTTP(res, req)
+
+	if res.Body.String() != `{"card":"4111111111111111"}` {
+		t.Fatalf("the handler should see the original body but got %s", res.Body.String())
+	}
+
+	fields := observed.All()[0].ContextMap()
+	for _, key := range []string{"request-body", "response-body"} {
+		if fields[key] != `{"card":"************1111"}` {
+			t.Fatalf("%s should be redacted but was %v", key, fields[key])
+		}
+	}
+}
+
+func TestRedactBodyKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestBody: true,
+		RedactBodyKeys: []string{"password"},
+	}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, body := range []string{`{"user":"bob","password":"hunter2","nested":{"PASSWORD":"x"}}`, `["password"]`, `{"Password":"hunter2"}`} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", testPath, strings.NewReader(body))
+		r.ServeHTTP(res, req)
+	}
+
+	if body := observed.All()[0].ContextMap()["request-body"]; body != `{"nested":{"PASSWORD":"***"},"password":"***","user":"bob"}` {
+		t.Fatalf("password should be redacted at any depth but body was %v", body)
+	}
+	if body := observed.All()[1].ContextMap()["request-body"]; body != `["password"]` {
+		t.Fatalf("non-object bodies should be left untouched but body was %v", body)
+	}
+	if body := observed.All()[2].ContextMap()["request-body"]; body != `{"Password":"***"}` {
+		t.Fatalf("keys should be matched case-insensitively but body was %v", body)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer t0ken"}, "Accept": {"*/*"}}
+	got := redactHeader(h, []string{"authorization", "X-Missing"})
+	if got.Get("Authorization") != "***" || got.Get("Accept") != "*/*" {
+		t.Fatalf("unexpected redacted header %v", got)
+	}
+	if h.Get("Authorization") != "Bearer t0ken" {
+		t.Fatal("the original header should not be modified")
+	}
+}
+
+func TestRecoveryRedactHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(RecoveryWithConfig(logger, &RecoveryConfig{RedactHeaders: []string{"Authorization"}}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer t0ken" {
+			t.Error("handlers should see the real header")
+		}
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.Header.Set("Authorization", "Bearer t0ken")
+	r.ServeHTTP(res, req)
+
+	dump := observed.All()[0].ContextMap()["request"].(string)
+	if strings.Contains(dump, "t0ken") || !strings.Contains(dump, "Authorization: ***") {
+		t.Fatalf("the request dump should redact Authorization: %q", dump)
+	}
+}
+
+func TestRedactPreservesLargeIntegers(t *testing.T) {
+	body := []byte(`{"id":12345678901234567,"password":"x","ts":[16970000000000001]}`)
+
+	if got, want := string(redactBody(&Config{RedactBodyKeys: []string{"password"}}, body)), `{"id":12345678901234567,"password":"***","ts":[16970000000000001]}`; got != want {
+		t.Fatalf("redactBody = %s, want %s", got, want)
+	}
+	if got, want := string(redactJSON(body, maskCard)), `{"id":12345678901234567,"password":"x","ts":[16970000000000001]}`; got != want {
+		t.Fatalf("redactJSON = %s, want %s", got, want)
+	}
+}
+
+func TestRedactBodyKeysAndFunc(t *testing.T) {
+	body := []byte(`{"z":1,"card":"4111111111111111","items":[{"card":"5500000000000004","a":"b","password":"x"}],"password":"y"}`)
+
+	conf := &Config{RedactBodyKeys: []string{"password"}, RedactFunc: func(key string, value interface{}) (interface{}, bool) {
+		if key == "password" {
+			t.Error("RedactFunc should not see keys redacted by RedactBodyKeys")
+		}
+		return maskCard(key, value)
+	}}
+	want := `{"card":"************1111","items":[{"a":"b","card":"************0004","password":"***"}],"password":"***","z":1}`
+	if got := string(redactBody(conf, body)); got != want {
+		t.Fatalf("redactBody = %s, want %s", got, want)
+	}
+}
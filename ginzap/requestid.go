@@ -0,0 +1,44 @@
+package ginzap
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDKey = "_ginzap/request-id"
+
+// RequestIDFromContext returns the request id resolved by a middleware
+// configured with RequestIDHeader, or "" when there is none.
+func RequestIDFromContext(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// resolveRequestID reads the RequestIDHeader, generating a UUID when it is
+// absent and GenerateRequestID is set, then stores the id for
+// RequestIDFromContext and sets it on the response.
+func resolveRequestID(c *gin.Context, conf *Config) string {
+	if conf.RequestIDHeader == "" {
+		return ""
+	}
+	id := c.GetHeader(conf.RequestIDHeader)
+	if id == "" && conf.GenerateRequestID {
+		id = newUUID()
+	}
+	if id == "" {
+		return ""
+	}
+	c.Set(requestIDKey, id)
+	c.Header(conf.RequestIDHeader, id)
+	return id
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
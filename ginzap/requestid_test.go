@@ -0,0 +1,69 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestIDHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{RequestIDHeader: "X-Request-ID", GenerateRequestID: true}))
+
+	var seen []string
+	r.GET(testPath, func(c *gin.Context) {
+		seen = append(seen, RequestIDFromContext(c))
+		c.Status(204)
+	})
+
+	var echoed []string
+	for _, id := range []string{"lb-1", ""} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		r.ServeHTTP(res, req)
+		echoed = append(echoed, res.Header().Get("X-Request-ID"))
+	}
+
+	if id := observed.All()[0].ContextMap()["request-id"]; id != "lb-1" || seen[0] != "lb-1" || echoed[0] != "lb-1" {
+		t.Fatalf("the header value should be used, logged %v, seen %q, echoed %q", id, seen[0], echoed[0])
+	}
+	id, _ := observed.All()[1].ContextMap()["request-id"].(string)
+	if !uuidPattern.MatchString(id) || seen[1] != id || echoed[1] != id {
+		t.Fatalf("a UUID should be generated, logged %q, seen %q, echoed %q", id, seen[1], echoed[1])
+	}
+}
+
+func TestRequestIDHeaderUnset(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{GenerateRequestID: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	req.Header.Set("X-Request-Id", "lb-1")
+	r.ServeHTTP(res, req)
+
+	if _, ok := observed.All()[0].ContextMap()["request-id"]; ok {
+		t.Fatal("request-id should not be logged without RequestIDHeader")
+	}
+}
@@ -0,0 +1,28 @@
+package ginzap
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// rateSampler picks requests at random for Config.SampleRate. It uses a
+// per-middleware math/rand source, which is cheap but not safe for
+// concurrent use on its own.
+type rateSampler struct {
+	rate float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newRateSampler(rate float64) *rateSampler {
+	return &rateSampler{rate: rate, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// sample reports whether the current request should be logged.
+func (s *rateSampler) sample() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < s.rate
+}
@@ -0,0 +1,58 @@
+package ginzap
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateSampler(t *testing.T) {
+	s := &rateSampler{rate: 0.25, rng: rand.New(rand.NewSource(1))}
+	var n int
+	for i := 0; i < 10000; i++ {
+		if s.sample() {
+			n++
+		}
+	}
+	if n < 2300 || n > 2700 {
+		t.Fatalf("about 2500 of 10000 requests should be sampled but %d were", n)
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{SampleRate: 0.000001}))
+
+	for _, status := range []int{200, 404, 503} {
+		status := status
+		r.GET(fmt.Sprintf("/%d", status), func(c *gin.Context) {
+			c.Status(status)
+		})
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, path := range []string{"/200", "/404", "/503"} {
+			res := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+			r.ServeHTTP(res, req)
+		}
+	}
+
+	if len(observed.All()) != 20 {
+		t.Fatalf("only the 20 failures should be logged but there're %d lines", len(observed.All()))
+	}
+	for _, entry := range observed.All() {
+		if status := entry.ContextMap()["status"]; status != int64(503) {
+			t.Fatalf("a sampled-out request was logged with status %v", status)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package ginzap
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// sanitizeUTF8 returns s with every byte that is not part of a valid UTF-8
+// sequence percent-escaped, e.g. "%FF", and whether there was any.
+func sanitizeUTF8(s string) (string, bool) {
+	if utf8.ValidString(s) {
+		return s, false
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, "%%%02X", s[i])
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), true
+}
@@ -0,0 +1,64 @@
+package ginzap
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSanitizeUTF8(t *testing.T) {
+	tests := []struct {
+		in, want string
+		changed  bool
+	}{
+		{"/users/é", "/users/é", false},
+		{"/a\xffb", "/a%FFb", true},
+		{"\xe2\x82", "%E2%82", true},
+		{"x\xc0\xafé", "x%C0%AFé", true},
+	}
+	for _, tt := range tests {
+		got, changed := sanitizeUTF8(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Fatalf("sanitizeUTF8(%q) should be %q, %v but was %q, %v", tt.in, tt.want, tt.changed, got, changed)
+		}
+	}
+}
+
+func TestSanitizeUTF8Fields(t *testing.T) {
+	for _, sanitize := range []bool{false, true} {
+		r := gin.New()
+		logger, observed := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, &Config{SanitizeUTF8: sanitize, DecodeQuery: true}))
+		r.NoRoute(func(c *gin.Context) {
+			c.Status(404)
+		})
+
+		req := httptest.NewRequest("GET", "/probe?q=%FF", nil)
+		req.URL.Path = "/probe\xff"
+		req.Header.Set("User-Agent", "scanner\xc0")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		fields := observed.All()[0].ContextMap()
+		if !sanitize {
+			if fields["path"] != "/probe\xff" || fields["path-sanitized"] != nil {
+				t.Fatalf("fields should be logged as received without SanitizeUTF8, got %v", fields)
+			}
+			continue
+		}
+		if fields["path"] != "/probe%FF" || fields["query"] != "q=%FF" || fields["user-agent"] != "scanner%C0" || fields["path-sanitized"] != true {
+			t.Fatalf("fields should be sanitized, got %v", fields)
+		}
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{SanitizeUTF8: true}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath+"?q=é", nil))
+	if _, ok := observed.All()[0].ContextMap()["path-sanitized"]; ok {
+		t.Fatal("path-sanitized should not be logged for clean requests")
+	}
+}
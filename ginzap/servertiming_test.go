@@ -0,0 +1,48 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmitServerTimingHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, _ := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{EmitServerTimingHeader: true, LogResponseBody: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		time.Sleep(5 * time.Millisecond)
+		c.JSON(200, gin.H{"ok": true})
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{testPath, "/empty"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+
+		header := res.Header().Get("Server-Timing")
+		if !strings.HasPrefix(header, "app;dur=") {
+			t.Fatalf("%s: Server-Timing should be set but was %q", path, header)
+		}
+		ms, err := strconv.ParseFloat(strings.TrimPrefix(header, "app;dur="), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == testPath && ms < 5 {
+			t.Fatalf("Server-Timing should include handler time but was %vms", ms)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ginzap
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldKind is the kind of the value of a Field.
+type FieldKind uint8
+
+const (
+	// FieldAny holds any other value, such as a map[string]interface{} for
+	// objects or a []interface{} for arrays.
+	FieldAny FieldKind = iota
+	// FieldString holds a string.
+	FieldString
+	// FieldInt holds an int64.
+	FieldInt
+	// FieldUint holds a uint64.
+	FieldUint
+	// FieldFloat holds a float64.
+	FieldFloat
+	// FieldBool holds a bool.
+	FieldBool
+	// FieldDuration holds a time.Duration.
+	FieldDuration
+	// FieldTime holds a time.Time.
+	FieldTime
+)
+
+// Field is a logged field in a form independent of zap, for FieldSink.
+type Field struct {
+	Key   string
+	Kind  FieldKind
+	Value interface{}
+}
+
+// FieldSink receives the lines of GinzapWithSink, so they can be written to a
+// logging backend other than zap by translating Fields.
+type FieldSink interface {
+	Log(level zapcore.Level, msg string, fields []Field)
+}
+
+// GinzapWithSink returns a gin.HandlerFunc logging like GinzapWithConfig, but
+// to sink. Fields added through Config, such as Context, are still built
+// with zap and converted to Fields.
+func GinzapWithSink(sink FieldSink, conf *Config) gin.HandlerFunc {
+	return GinzapWithConfig(sinkLogger{sink}, conf)
+}
+
+// ZapSink returns a FieldSink writing to logger.
+func ZapSink(logger *zap.Logger) FieldSink {
+	return zapSink{logger}
+}
+
+type zapSink struct {
+	logger *zap.Logger
+}
+
+func (s zapSink) Log(level zapcore.Level, msg string, fields []Field) {
+	zf := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zf[i] = f.zapField()
+	}
+	s.logger.Log(level, msg, zf...)
+}
+
+// zapField converts f back to a zap field.
+func (f Field) zapField() zap.Field {
+	switch v := f.Value.(type) {
+	case string:
+		return zap.String(f.Key, v)
+	case int64:
+		return zap.Int64(f.Key, v)
+	case uint64:
+		return zap.Uint64(f.Key, v)
+	case float64:
+		return zap.Float64(f.Key, v)
+	case bool:
+		return zap.Bool(f.Key, v)
+	case time.Duration:
+		return zap.Duration(f.Key, v)
+	case time.Time:
+		return zap.Time(f.Key, v)
+	}
+	return zap.Any(f.Key, f.Value)
+}
+
+// sinkLogger adapts a FieldSink to LevelLogger.
+type sinkLogger struct {
+	sink FieldSink
+}
+
+func (l sinkLogger) Log(level zapcore.Level, msg string, fields ...zap.Field) {
+	l.sink.Log(level, msg, sinkFields(fields))
+}
+
+func (l sinkLogger) Debug(msg string, fields ...zap.Field) {
+	l.Log(zapcore.DebugLevel, msg, fields...)
+}
+
+func (l sinkLogger) Info(msg string, fields ...zap.Field) {
+	l.Log(zapcore.InfoLevel, msg, fields...)
+}
+
+func (l sinkLogger) Error(msg string, fields ...zap.Field) {
+	l.Log(zapcore.ErrorLevel, msg, fields...)
+}
+
+// sinkFields converts zap fields to Fields, encoding each through a
+// zapcore.MapObjectEncoder. A field may add several keys, such as zap.Error
+// with "errorVerbose", or none, such as zap.Skip.
+func sinkFields(fields []zap.Field) []Field {
+	out := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if v, ok := enc.Fields[f.Key]; ok && len(enc.Fields) == 1 {
+			out = append(out, newField(f.Key, v))
+			continue
+		}
+		keys := make([]string, 0, len(enc.Fields))
+		for k := range enc.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, newField(k, enc.Fields[k]))
+		}
+	}
+	return out
+}
+
+// newField returns the Field of a value stored by a zapcore.MapObjectEncoder,
+// with integers and floats widened.
+func newField(key string, v interface{}) Field {
+	switch v := v.(type) {
+	case string:
+		return Field{key, FieldString, v}
+	case int:
+		return Field{key, FieldInt, int64(v)}
+	case int8:
+		return Field{key, FieldInt, int64(v)}
+	case int16:
+		return Field{key, FieldInt, int64(v)}
+	case int32:
+		return Field{key, FieldInt, int64(v)}
+	case int64:
+		return Field{key, FieldInt, v}
+	case uint:
+		return Field{key, FieldUint, uint64(v)}
+	case uint8:
+		return Field{key, FieldUint, uint64(v)}
+	case uint16:
+		return Field{key, FieldUint, uint64(v)}
+	case uint32:
+		return Field{key, FieldUint, uint64(v)}
+	case uint64:
+		return Field{key, FieldUint, v}
+	case uintptr:
+		return Field{key, FieldUint, uint64(v)}
+	case float32:
+		return Field{key, FieldFloat, float64(v)}
+	case float64:
+		return Field{key, FieldFloat, v}
+	case bool:
+		return Field{key, FieldBool, v}
+	case time.Duration:
+		return Field{key, FieldDuration, v}
+	case time.Time:
+		return Field{key, FieldTime, v}
+	}
+	return Field{key, FieldAny, v}
+}
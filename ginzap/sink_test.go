@@ -0,0 +1,97 @@
+package ginzap
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type sinkEntry struct {
+	level  zapcore.Level
+	msg    string
+	fields map[string]Field
+}
+
+type recordingSink struct {
+	entries []sinkEntry
+}
+
+func (s *recordingSink) Log(level zapcore.Level, msg string, fields []Field) {
+	m := make(map[string]Field, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f
+	}
+	s.entries = append(s.entries, sinkEntry{level, msg, m})
+}
+
+func TestGinzapWithSink(t *testing.T) {
+	r := gin.New()
+	sink := &recordingSink{}
+	r.Use(GinzapWithSink(sink, &Config{
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.Strings("tags", []string{"a"}), zap.Error(errors.New("ctx"))}
+		},
+	}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(500)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	if len(sink.entries) != 2 {
+		t.Fatalf("two lines should be logged, got %d", len(sink.entries))
+	}
+	ok := sink.entries[0]
+	if ok.level != zapcore.InfoLevel {
+		t.Fatalf("a successful request should be logged at Info, got %s", ok.level)
+	}
+	for key, want := range map[string]Field{
+		"status": {"status", FieldInt, int64(204)},
+		"path":   {"path", FieldString, testPath},
+		"error":  {"error", FieldString, "ctx"},
+	} {
+		if got := ok.fields[key]; got != want {
+			t.Fatalf("%s should be %+v but was %+v", key, want, got)
+		}
+	}
+	if f := ok.fields["latency"]; f.Kind != FieldDuration {
+		t.Fatalf("latency should be a duration, got %+v", f)
+	}
+	if f := ok.fields["tags"]; f.Kind != FieldAny || len(f.Value.([]interface{})) != 1 {
+		t.Fatalf("tags should be an array, got %+v", f)
+	}
+
+	if failed := sink.entries[1]; failed.level != zapcore.ErrorLevel || failed.msg != "failed" {
+		t.Fatalf("an error should be logged at Error with its message, got %s %q", failed.level, failed.msg)
+	}
+}
+
+func TestZapSink(t *testing.T) {
+	logger, observed := NewObservedLogger()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ZapSink(logger).Log(zapcore.WarnLevel, "msg", []Field{
+		{"status", FieldInt, int64(200)},
+		{"latency", FieldDuration, time.Second},
+		{"time", FieldTime, now},
+		{"meta", FieldAny, map[string]interface{}{"a": "b"}},
+	})
+
+	entry := observed.All()[0]
+	fields := entry.ContextMap()
+	if entry.Level != zapcore.WarnLevel || fields["status"] != int64(200) || fields["latency"] != time.Second || !fields["time"].(time.Time).Equal(now) {
+		t.Fatalf("fields should round-trip through zap, got %s %v", entry.Level, fields)
+	}
+	if fields["meta"].(map[string]interface{})["a"] != "b" {
+		t.Fatalf("meta should be an object, got %v", fields["meta"])
+	}
+}
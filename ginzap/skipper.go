@@ -0,0 +1,65 @@
+package ginzap
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// skipLoggingKey is the context key set by SkipLogging.
+const skipLoggingKey = "_ginzap/skip-logging"
+
+// SkipLogging drops the access log line of the current request, e.g. for a
+// token refresh whose logging depends on its content. It wins over every
+// option forcing lines out, errors and LogOnlyWhen included.
+func SkipLogging(c *gin.Context) {
+	c.Set(skipLoggingKey, true)
+}
+
+// handlerStartKey is the context key set by MarkHandlerStart.
+const handlerStartKey = "_ginzap/handler-start"
+
+// clockKey is the context key of the Config.Clock of the access logger, so
+// MarkHandlerStart reads the same clock. It is only set for a custom Clock.
+const clockKey = "_ginzap/clock"
+
+// MarkHandlerStart records that the handler of the current request starts
+// now, for the access log line to carry "handler-latency", the time from
+// the mark until the line is assembled, next to "latency". Handlers call it
+// first thing, to tell their own time from that of the middleware. It reads
+// the Config.Clock of the access logger.
+func MarkHandlerStart(c *gin.Context) {
+	now := timeNow
+	if v, ok := c.Get(clockKey); ok {
+		if clock, ok := v.(func() time.Time); ok {
+			now = clock
+		}
+	}
+	c.Set(handlerStartKey, now())
+}
+
+var defaultSkipPaths = []string{"/healthz", "/readyz", "/metrics", "/favicon.ico"}
+
+// DefaultSkipper returns a Skipper skipping common noise, /healthz, /readyz,
+// /metrics and /favicon.ico, plus the extra paths. Paths match exactly, with
+// or without a trailing slash.
+func DefaultSkipper(extra ...string) Skipper {
+	paths := make(map[string]bool, len(defaultSkipPaths)+len(extra))
+	for _, list := range [][]string{defaultSkipPaths, extra} {
+		for _, path := range list {
+			paths[trimTrailingSlash(path)] = true
+		}
+	}
+	return func(c *gin.Context) bool {
+		return paths[trimTrailingSlash(c.Request.URL.Path)]
+	}
+}
+
+// trimTrailingSlash removes one trailing slash, keeping the root path.
+func trimTrailingSlash(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
@@ -0,0 +1,124 @@
+package ginzap
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDefaultSkipper(t *testing.T) {
+	skip := DefaultSkipper("/internal/status", "/")
+
+	for path, want := range map[string]bool{
+		"/healthz":          true,
+		"/readyz/":          true,
+		"/metrics":          true,
+		"/favicon.ico":      true,
+		"/internal/status/": true,
+		"/":                 true,
+		"/healthz/deep":     false,
+		"/metricsx":         false,
+		"/users":            false,
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", path, nil)
+		if got := skip(c); got != want {
+			t.Errorf("DefaultSkipper()(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSkipLogging(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{}))
+
+	r.POST("/token", func(c *gin.Context) {
+		if c.Query("refresh") != "" {
+			SkipLogging(c)
+		}
+		_ = c.Error(errors.New("expired"))
+		c.Status(401)
+	})
+
+	for _, path := range []string{"/token?refresh=1", "/token"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if observed.Len() != 1 || observed.All()[0].ContextMap()["query"] != "" {
+		t.Fatalf("only the request not calling SkipLogging should be logged, got %d entries", observed.Len())
+	}
+}
+
+func TestMarkHandlerStart(t *testing.T) {
+	defer func(orig func() time.Time) { timeNow = orig }(timeNow)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// The request starts, the handler is marked, then every later read is at
+	// 100ms.
+	ticks := []time.Time{start, start.Add(30 * time.Millisecond), start.Add(100 * time.Millisecond)}
+	timeNow = func() time.Time {
+		t := ticks[0]
+		if len(ticks) > 1 {
+			ticks = ticks[1:]
+		}
+		return t
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{}))
+	r.GET("/marked", func(c *gin.Context) {
+		MarkHandlerStart(c)
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/marked", nil))
+
+	fields := observed.All()[0].ContextMap()
+	if fields["handler-latency"] != 70*time.Millisecond || fields["latency"] != 100*time.Millisecond {
+		t.Fatalf("handler-latency should span from the mark, got %v of %v", fields["handler-latency"], fields["latency"])
+	}
+
+	timeNow = time.Now
+	r.GET("/unmarked", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unmarked", nil))
+	if _, ok := observed.All()[1].ContextMap()["handler-latency"]; ok {
+		t.Fatal("handler-latency should not be logged without a mark")
+	}
+}
+
+func TestMarkHandlerStartClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// The request starts, the handler is marked, then every later read is at
+	// 100ms.
+	ticks := []time.Time{start, start.Add(30 * time.Millisecond), start.Add(100 * time.Millisecond)}
+	clock := func() time.Time {
+		t := ticks[0]
+		if len(ticks) > 1 {
+			ticks = ticks[1:]
+		}
+		return t
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{Clock: clock}))
+	r.GET(testPath, func(c *gin.Context) {
+		MarkHandlerStart(c)
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+	fields := observed.All()[0].ContextMap()
+	if fields["handler-latency"] != 70*time.Millisecond || fields["latency"] != 100*time.Millisecond {
+		t.Fatalf("handler-latency should be measured with Config.Clock, got %v of %v", fields["handler-latency"], fields["latency"])
+	}
+}
@@ -0,0 +1,81 @@
+package ginzap
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+const defaultSampleCacheSize = 1024
+
+// stackSampler counts panics per stack signature in a bounded LRU.
+type stackSampler struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[uint64]*list.Element
+	order   *list.List
+}
+
+type stackCount struct {
+	signature uint64
+	count     int
+}
+
+func newStackSampler(size int) *stackSampler {
+	if size <= 0 {
+		size = defaultSampleCacheSize
+	}
+	return &stackSampler{
+		size:    size,
+		entries: make(map[uint64]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// observe records one occurrence of signature and returns how many times it
+// has been seen while tracked.
+func (s *stackSampler) observe(signature uint64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[signature]; ok {
+		s.order.MoveToFront(el)
+		sc := el.Value.(*stackCount)
+		sc.count++
+		return sc.count
+	}
+
+	if s.order.Len() >= s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*stackCount).signature)
+	}
+	s.entries[signature] = s.order.PushFront(&stackCount{signature: signature, count: 1})
+	return 1
+}
+
+// stackSignature hashes the program counters of the calling goroutine. Called
+// from a deferred recover, the frames of the panicking call are still on the
+// stack, so identical panic sites produce identical signatures regardless of
+// goroutine ids or argument values.
+func stackSignature() uint64 {
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, pc := range pcs[:n] {
+		binary.LittleEndian.PutUint64(buf[:], uint64(pc))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// messageSignature hashes a panic message for RecoveryConfig.StackSampleEvery.
+func messageSignature(msg string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(msg))
+	return h.Sum64()
+}
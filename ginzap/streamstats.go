@@ -0,0 +1,47 @@
+package ginzap
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamStatsWriter counts the writes and bytes of a response without
+// keeping its content.
+type streamStatsWriter struct {
+	gin.ResponseWriter
+	writes int
+	bytes  int64
+}
+
+func (w *streamStatsWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.writes++
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *streamStatsWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.writes++
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the io.ReaderFrom of the wrapped writer reachable, see
+// bodyLogWriter.ReadFrom. A delegated ReadFrom counts as a single write.
+func (w *streamStatsWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err := rf.ReadFrom(r)
+		w.writes++
+		w.bytes += n
+		return n, err
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *streamStatsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,39 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLogStreamStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogStreamStats: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			_, _ = c.Writer.WriteString("data: x\n\n")
+			c.Writer.Flush()
+		}
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["write-count"] != int64(3) || fields["response-size"] != int64(3*len("data: x\n\n")) {
+		t.Fatalf("unexpected stream stats: %v", fields)
+	}
+	if res.Body.Len() != 3*len("data: x\n\n") {
+		t.Fatalf("the response should be forwarded, got %q", res.Body.String())
+	}
+}
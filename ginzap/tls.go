@@ -0,0 +1,39 @@
+package ginzap
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TLSVersionName returns the name of a tls.ConnectionState.Version, such as
+// "TLS 1.3", or its hex value for unknown versions.
+func TLSVersionName(version uint16) string {
+	switch version {
+	case tls.VersionSSL30: //nolint:staticcheck // still reported by old clients
+		return "SSL 3.0"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// tlsFields returns the fields logged by Config.LogTLS.
+func tlsFields(state *tls.ConnectionState) []zapcore.Field {
+	fields := []zapcore.Field{
+		zap.String("tls-version", TLSVersionName(state.Version)),
+		zap.String("tls-cipher", tls.CipherSuiteName(state.CipherSuite)),
+	}
+	if state.ServerName != "" {
+		fields = append(fields, zap.String("tls-server-name", state.ServerName))
+	}
+	return fields
+}
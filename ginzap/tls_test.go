@@ -0,0 +1,55 @@
+package ginzap
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTLSVersionName(t *testing.T) {
+	for version, want := range map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+		0x7f1c:           "0x7F1C",
+	} {
+		if got := TLSVersionName(version); got != want {
+			t.Errorf("TLSVersionName(%#x) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+func TestLogTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogTLS: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, state := range []*tls.ConnectionState{
+		{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256, ServerName: "api.example.com"},
+		nil,
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		req.TLS = state
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["tls-version"] != "TLS 1.3" || fields["tls-cipher"] != "TLS_AES_128_GCM_SHA256" || fields["tls-server-name"] != "api.example.com" {
+		t.Fatalf("unexpected TLS fields %v", fields)
+	}
+	if _, ok := observed.All()[1].ContextMap()["tls-version"]; ok {
+		t.Fatal("plain HTTP requests should not log TLS fields")
+	}
+}
@@ -0,0 +1,58 @@
+package ginzap
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkingBufferSize mirrors net/http's bufferBeforeChunkingSize: an HTTP/1.1
+// response body of at most this many bytes that is not flushed before the
+// handler returns is sent with a Content-Length set by net/http.
+const chunkingBufferSize = 2048
+
+// transferWriter records whether the handler flushed the response, which
+// makes net/http send it chunked when no Content-Length is set.
+type transferWriter struct {
+	gin.ResponseWriter
+	flushed bool
+}
+
+func (w *transferWriter) Flush() {
+	w.flushed = true
+	w.ResponseWriter.Flush()
+}
+
+// ReadFrom keeps the io.ReaderFrom of the wrapped writer reachable, see
+// bodyLogWriter.ReadFrom.
+func (w *transferWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *transferWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isChunked reports whether the response used chunked transfer encoding:
+// declared by the handler, or chosen by net/http for an HTTP/1.1 body without
+// a Content-Length that was flushed, or outgrew its buffer, before the
+// handler returned.
+func isChunked(req *http.Request, w gin.ResponseWriter, flushed bool) bool {
+	header := w.Header()
+	if strings.Contains(strings.ToLower(header.Get("Transfer-Encoding")), "chunked") {
+		return true
+	}
+	if header.Get("Content-Length") != "" || !req.ProtoAtLeast(1, 1) || req.Method == http.MethodHead {
+		return false
+	}
+	if status := w.Status(); status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	return flushed || w.Size() > chunkingBufferSize
+}
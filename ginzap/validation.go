@@ -0,0 +1,95 @@
+package ginzap
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldError is the subset of validator.FieldError needed for logging, so
+// the validator package does not have to be imported.
+type fieldError interface {
+	Field() string
+	Tag() string
+	Value() interface{}
+}
+
+// redactedFieldError logs the rejected value of a field named in
+// RedactBodyKeys as "***".
+type redactedFieldError struct {
+	fieldError
+}
+
+func (redactedFieldError) Value() interface{} {
+	return redacted
+}
+
+// keySeparators strips the word separators of JSON keys.
+var keySeparators = strings.NewReplacer("_", "", "-", "")
+
+// redactsField reports whether the field of e is named in keys, compared
+// case-insensitively against its name and struct field name, and once
+// without the "_" and "-" of snake and kebab case JSON keys, so that
+// "access_token" matches AccessToken.
+func redactsField(e fieldError, keys []string) bool {
+	names := []string{e.Field()}
+	if sf, ok := e.(interface{ StructField() string }); ok {
+		names = append(names, sf.StructField())
+	}
+	for _, key := range keys {
+		bare := keySeparators.Replace(key)
+		for _, name := range names {
+			if strings.EqualFold(name, key) || strings.EqualFold(name, bare) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type fieldErrors []fieldError
+
+func (fe fieldErrors) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range fe {
+		e := e
+		if err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(oe zapcore.ObjectEncoder) error {
+			oe.AddString("field", e.Field())
+			oe.AddString("tag", e.Tag())
+			return oe.AddReflected("value", e.Value())
+		})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validationErrors collects the field errors of every validator.ValidationErrors
+// (a slice of FieldError) found in errs, with the values of the fields named
+// in redact, see redactsField, logged as "***".
+func validationErrors(errs []*gin.Error, redact []string) fieldErrors {
+	var out fieldErrors
+	add := func(fe fieldError) {
+		if redactsField(fe, redact) {
+			fe = redactedFieldError{fe}
+		}
+		out = append(out, fe)
+	}
+	for _, e := range errs {
+		if fe, ok := e.Err.(fieldError); ok {
+			add(fe)
+			continue
+		}
+		v := reflect.ValueOf(e.Err)
+		if v.Kind() != reflect.Slice {
+			continue
+		}
+		for i := 0; i < v.Len(); i++ {
+			if fe, ok := v.Index(i).Interface().(fieldError); ok {
+				add(fe)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package ginzap
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLogValidationErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogValidationErrors: true}))
+
+	type payload struct {
+		Name string `json:"name" binding:"required"`
+		Age  int    `json:"age" binding:"gte=18"`
+	}
+
+	r.POST(testPath, func(c *gin.Context) {
+		var p payload
+		if err := c.ShouldBindJSON(&p); err != nil {
+			_ = c.Error(err).SetType(gin.ErrorTypeBind)
+			c.Status(http.StatusBadRequest)
+		}
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, bytes.NewBufferString(`{"age":3}`))
+	r.ServeHTTP(res, req)
+
+	if len(observed.All()) != 1 {
+		t.Fatalf("Log should be 1 line but there're %d", len(observed.All()))
+	}
+
+	verrs, ok := observed.All()[0].ContextMap()["validation-errors"].([]interface{})
+	if !ok || len(verrs) != 2 {
+		t.Fatalf("validation-errors should have 2 entries but was %v", observed.All()[0].ContextMap()["validation-errors"])
+	}
+
+	first := verrs[0].(map[string]interface{})
+	if first["field"] != "Name" || first["tag"] != "required" || first["value"] != "" {
+		t.Fatalf("unexpected first validation error %v", first)
+	}
+	second := verrs[1].(map[string]interface{})
+	if second["field"] != "Age" || second["tag"] != "gte" || second["value"] != 3 {
+		t.Fatalf("unexpected second validation error %v", second)
+	}
+}
+
+func TestValidationErrorsIgnoresOtherErrors(t *testing.T) {
+	errs := []*gin.Error{{Err: http.ErrBodyNotAllowed}}
+	if verrs := validationErrors(errs, nil); len(verrs) != 0 {
+		t.Fatalf("plain errors should not produce validation errors but got %d", len(verrs))
+	}
+}
+
+func TestLogValidationErrorsRedacted(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogValidationErrors: true, RedactBodyKeys: []string{"password", "access_token"}}))
+
+	type payload struct {
+		Name        string `json:"name" binding:"min=3"`
+		Password    string `json:"password" binding:"min=8"`
+		AccessToken string `json:"access_token" binding:"len=32"`
+	}
+
+	r.POST(testPath, func(c *gin.Context) {
+		var p payload
+		if err := c.ShouldBindJSON(&p); err != nil {
+			_ = c.Error(err).SetType(gin.ErrorTypeBind)
+			c.Status(http.StatusBadRequest)
+		}
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", testPath, bytes.NewBufferString(`{"name":"al","password":"hunter2","access_token":"t0ken"}`))
+	r.ServeHTTP(res, req)
+
+	verrs, ok := observed.All()[0].ContextMap()["validation-errors"].([]interface{})
+	if !ok || len(verrs) != 3 {
+		t.Fatalf("validation-errors should have 3 entries but was %v", observed.All()[0].ContextMap()["validation-errors"])
+	}
+	for i, want := range []interface{}{"al", "***", "***"} {
+		if got := verrs[i].(map[string]interface{})["value"]; got != want {
+			t.Fatalf("value of %v should be %v but was %v", verrs[i], want, got)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package ginzap
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PathAPIVersion is an APIVersionFunc returning the first "vN" segment of the
+// request path, e.g. "v2" for /api/v2/users, or "" when there is none.
+func PathAPIVersion(c *gin.Context) string {
+	for _, segment := range strings.Split(c.Request.URL.Path, "/") {
+		if isVersionSegment(segment) {
+			return segment
+		}
+	}
+	return ""
+}
+
+func isVersionSegment(s string) bool {
+	if len(s) < 2 || (s[0] != 'v' && s[0] != 'V') {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
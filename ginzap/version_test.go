@@ -0,0 +1,71 @@
+package ginzap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPathAPIVersion(t *testing.T) {
+	tests := map[string]string{
+		"/v2/users":       "v2",
+		"/api/v10/orders": "v10",
+		"/users/v":        "",
+		"/users/vip":      "",
+		"/users":          "",
+	}
+	for path, want := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", path, nil)
+		if got := PathAPIVersion(c); got != want {
+			t.Errorf("PathAPIVersion(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAPIVersionFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		APIVersionFunc: func(c *gin.Context) string {
+			if accept := c.GetHeader("Accept"); strings.HasPrefix(accept, "application/vnd.api.") {
+				return strings.TrimSuffix(strings.TrimPrefix(accept, "application/vnd.api."), "+json")
+			}
+			return PathAPIVersion(c)
+		},
+	}))
+
+	r.GET("/v1/users", func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/users", func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, tt := range []struct{ path, accept string }{
+		{"/v1/users", ""},
+		{"/users", "application/vnd.api.v3+json"},
+		{"/users", ""},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		r.ServeHTTP(res, req)
+	}
+
+	for i, want := range []string{"v1", "v3"} {
+		if v := observed.All()[i].ContextMap()["api-version"]; v != want {
+			t.Fatalf("line %d: api-version should be %s but was %v", i, want, v)
+		}
+	}
+	if _, ok := observed.All()[2].ContextMap()["api-version"]; ok {
+		t.Fatal("api-version should be omitted when empty")
+	}
+}
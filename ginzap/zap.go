@@ -3,12 +3,16 @@
 package ginzap
 
 import (
-	"net"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"net/http"
-	"net/http/httputil"
+	"net/url"
 	"os"
 	"regexp"
-	"runtime/debug"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,12 +26,53 @@ type Fn func(c *gin.Context) []zapcore.Field
 // Skipper is a function to skip logs based on provided Context
 type Skipper func(c *gin.Context) bool
 
+// LevelFunc picks the level of the access log of a request without errors.
+type LevelFunc func(c *gin.Context) zapcore.Level
+
+// DefaultLevelFunc is a LevelFunc logging 5xx responses at Error, 4xx
+// responses at Warn and everything else at Info.
+func DefaultLevelFunc(c *gin.Context) zapcore.Level {
+	switch status := c.Writer.Status(); {
+	case status >= http.StatusInternalServerError:
+		return zapcore.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// LevelKey is the context key of the level set by SetLevel.
+const LevelKey = "ginzap_level"
+
+// SetLevel overrides the level of the access log of the current request,
+// taking precedence over Config.LevelFunc and Config.DefaultLevel. Requests
+// with errors are still logged at Error.
+func SetLevel(c *gin.Context, level zapcore.Level) {
+	c.Set(LevelKey, level)
+}
+
 // ZapLogger is the minimal logger interface compatible with zap.Logger
 type ZapLogger interface {
 	Info(msg string, fields ...zap.Field)
 	Error(msg string, fields ...zap.Field)
 }
 
+// LevelLogger is implemented by loggers that can write entries at any level,
+// such as *zap.Logger. The middleware uses Log instead of Info and Error
+// when the logger implements it, so that levels such as Warn and Debug,
+// from DefaultLevel, LevelFunc or ErrorLevelFunc, are kept; other loggers
+// get Info entries as Info and all others as Error.
+type LevelLogger interface {
+	Log(level zapcore.Level, msg string, fields ...zap.Field)
+}
+
+// debugLogger is implemented by loggers that can write Debug level entries,
+// such as *zap.Logger.
+type debugLogger interface {
+	Debug(msg string, fields ...zap.Field)
+}
+
 // Config is config setting for Ginzap
 type Config struct {
 	TimeFormat      string
@@ -36,15 +81,602 @@ type Config struct {
 	SkipPathRegexps []*regexp.Regexp
 	Context         Fn
 	DefaultLevel    zapcore.Level
+	// LevelFunc, when set, replaces DefaultLevel for requests without errors.
+	// Recovered panics are still logged at Error.
+	LevelFunc LevelFunc
 	// skip is a Skipper that indicates which logs should not be written.
-	// Optional.
+	// Optional. It is called after the handlers ran, so the response status
+	// is available through c.Writer.Status().
 	Skipper Skipper
+	// SkipMethods lists request methods, matched case-insensitively, that
+	// are not logged, e.g. HEAD and OPTIONS from health checkers. A request
+	// is skipped when it matches any of SkipPaths, SkipPathRegexps, Skipper
+	// or SkipMethods.
+	SkipMethods []string
+	// SkipStatusCodes lists response statuses that are not logged, e.g. 200
+	// and 304 on a noisy endpoint. Requests with errors in c.Errors are
+	// always logged.
+	SkipStatusCodes []int
+	// StatusSkipper, when set, skips requests whose response status it
+	// returns true for, e.g. every status below 400. Like SkipStatusCodes it
+	// never skips requests with errors.
+	StatusSkipper func(status int) bool
+	// SampleRate, between 0 and 1 exclusive, logs only that fraction of the
+	// requests without errors and with a status below ErrorStatusThreshold,
+	// chosen at random, e.g. 0.01 for 1%. Other values log every request.
+	SampleRate float64
+	// LogOnlyWhen, when set, is called after the handlers ran and only
+	// requests for which it returns true are logged, e.g. requests flagged
+	// for debugging. Failed requests, those with entries in c.Errors or a
+	// status of at least ErrorStatusThreshold, are logged regardless. Skipped
+	// requests stay skipped.
+	LogOnlyWhen func(c *gin.Context) bool
+	// LogRequestBody adds the request body as a "request-body" field when it is valid JSON.
+	// An empty body is logged as "", a request without body logs nothing, and
+	// a body that fails to read adds "request-body-read-error" instead.
+	LogRequestBody bool
+	// LogResponseBody adds the response body as a "response-body" field when it is valid JSON.
+	// The captured body is also returned by ResponseBody.
+	LogResponseBody bool
+	// ProtoUnmarshaler, when set, converts protobuf request and response
+	// bodies (application/x-protobuf, application/protobuf and the like) to
+	// JSON, e.g. with protojson, and the JSON is logged as "request-body" or
+	// "response-body", redacted like any JSON body. Bodies it returns false
+	// or invalid JSON for are handled as usual.
+	ProtoUnmarshaler func(contentType string, body []byte) (json.RawMessage, bool)
+	// PrettyBodies indents the logged JSON request and response bodies with
+	// two spaces, for reading logs by eye in development. Bodies that are not
+	// JSON are logged unchanged, and the size limits apply to the body as
+	// received.
+	PrettyBodies bool
+	// BodyAsObject logs valid JSON request and response bodies as nested
+	// JSON rather than as an escaped string, so backends can index their
+	// members. Other bodies, including truncated ones, stay strings.
+	// PrettyBodies has no effect on them.
+	BodyAsObject bool
+	// BodyContentTypes lists Content-Type prefixes, such as "text/" or
+	// "application/x-www-form-urlencoded", whose request and response bodies
+	// are logged as-is even when they are not valid JSON. JSON types are
+	// always validated. Such bodies are skipped when RedactFunc,
+	// RedactBodyKeys or LogBodyKeysOnly is set, since those parse JSON.
+	BodyContentTypes []string
+	// MaxRequestBodySize caps, in bytes, how much of the request body is
+	// captured for logging and hashing; 0 means unlimited. Only the captured
+	// prefix is held in memory, the rest is streamed to the handlers as
+	// usual. A truncated body is logged with "request-body-truncated": true
+	// and "request-body-size", its Content-Length when known.
+	MaxRequestBodySize int
+	// LogRequestBodySize adds "request-body-size", the bytes actually read
+	// from a request body captured whole for LogRequestBody or
+	// RequestHashFields, which for chunked uploads has no Content-Length.
+	// Truncated bodies carry their Content-Length there already.
+	LogRequestBodySize bool
+	// CountRequestBody adds "request-body-read", the bytes the handlers read
+	// from the request body, counted by wrapping c.Request.Body, without
+	// capturing it. It can differ from "content-length" for chunked uploads
+	// or handlers that stop reading early.
+	CountRequestBody bool
+	// MaxResponseBodySize caps, in bytes, how much of the response body is
+	// captured; 0 means unlimited. The whole response is still sent. A
+	// truncated body is logged with "response-body-truncated": true and
+	// "response-body-size". Truncated bodies are not valid JSON, so they are
+	// logged as plain strings cut at a rune boundary, or omitted when
+	// redaction or LogBodyKeysOnly would need to parse them.
+	//
+	// Responses with a gzip or deflate Content-Encoding are decompressed
+	// before logging, up to MaxResponseBodySize bytes or 1 MiB when it is
+	// unlimited; past that they are logged as truncated, without a size.
+	MaxResponseBodySize int
+	// ResponseBodyContentTypes, when set, lists the Content-Type prefixes,
+	// such as "application/json", of the responses buffered for
+	// LogResponseBody and LogResponseFieldCount; others, like file downloads
+	// and images, are not buffered at all and log no "response-body". The
+	// type is checked on the first write, sniffed from the bytes when the
+	// handler has not set the header.
+	ResponseBodyContentTypes []string
+	// BodyPrefixBytes, when positive, logs request and response bodies
+	// longer than this many bytes as their first BodyPrefixBytes bytes, cut
+	// at a rune boundary and followed by "...", whatever their content type,
+	// with "request-body-size" or "response-body-size", the size of the
+	// whole body when known. Like truncated bodies, prefixes are omitted when
+	// redaction or LogBodyKeysOnly would need to parse them.
+	BodyPrefixBytes int
+	// LogTLS adds "tls-version" (e.g. "TLS 1.3"), "tls-cipher" and, when the
+	// client sent SNI, "tls-server-name" for requests served over TLS.
+	LogTLS bool
+	// Clock, when set, replaces time.Now for the start and end times of the
+	// request, and so for "latency" and "time", and for the DedupWindow
+	// checks, e.g. to inject a frozen clock in tests. Optional.
+	Clock func() time.Time
+	// Observer, when set, is called with the method, route template, status
+	// and latency of every request that is logged. Optional.
+	Observer Observer
+	// SlowThreshold adds "slow": true and raises the level to at least Warn
+	// for requests whose latency exceeds it. Optional, zero disables it.
+	SlowThreshold time.Duration
+	// FlagNonstandardMethods adds "method-nonstandard": true and raises the
+	// level to at least Warn when the method is not one of the uppercase
+	// methods of RFC 9110 and RFC 5789, such as "get" or a custom verb, which
+	// often points at buggy clients or probing. "method" is unchanged.
+	FlagNonstandardMethods bool
+	// FlagEmptyResponses adds "empty-response": true to 2xx responses that
+	// wrote no body, going by the size gin tracks, which usually means a
+	// handler forgot to render. 204 and 205, whose body is empty by
+	// definition, and HEAD requests are not flagged.
+	FlagEmptyResponses bool
+	// UseFullPath adds "route", the matched route template such as
+	// /users/:id, next to the concrete "path", to group lines by endpoint
+	// with a low cardinality. Requests matching no route fall back to the
+	// path. As templates hold no parameter values, "route" is also the field
+	// to rely on when paths carry secrets, see MaskPathSegments.
+	UseFullPath bool
+	// LogHandlerName adds "handler", the fully-qualified name of the last
+	// handler of the chain, as returned by c.HandlerName, e.g.
+	// "main.getUser".
+	LogHandlerName bool
+	// LogProtocol adds "proto", the request's protocol version such as
+	// "HTTP/1.1" or "HTTP/2.0".
+	LogProtocol bool
+	// LogRequestURI adds "request-uri", the request target as sent by the
+	// client, before any rewrite by middleware: usually the path and query,
+	// or the absolute URI of proxy requests. Its path is masked like "path"
+	// and the values of its query parameters named in RedactBodyKeys,
+	// compared case-insensitively, are logged as "***".
+	LogRequestURI bool
+	// MaskPathSegments lists the zero-based indexes of path segments replaced
+	// by "*" in the logged path, e.g. []int{1} logs /reset/<token>/confirm as
+	// /reset/*/confirm. Routing and handlers still see the real path.
+	MaskPathSegments []int
+	// PathMaskFunc rewrites the path before it is logged, replacing
+	// MaskPathSegments when both are set.
+	PathMaskFunc func(path string) string
+	// QueueStartContextKey is the context key holding the time.Time at which the
+	// server accepted the request. When set, the time spent queued before this
+	// middleware ran is logged as "queue-wait".
+	QueueStartContextKey string
+	// StreamIDContextKey is the context key holding the HTTP/2 stream id, as
+	// populated by a server hook. When present it is logged as "stream-id".
+	StreamIDContextKey string
+	// PrincipalTypeContextKey is the context key holding the kind of caller,
+	// such as "user", "service" or "anonymous", as set by the authentication
+	// middleware. When present it is logged as "principal-type".
+	PrincipalTypeContextKey string
+	// LogValidationErrors adds a "validation-errors" field describing each
+	// failed rule when c.Errors holds gin binding validation errors. The
+	// rejected values of fields named in RedactBodyKeys are logged as "***".
+	LogValidationErrors bool
+	// DualTimestamp emits the formatted "time" field together with "ts", the
+	// end time in milliseconds since the Unix epoch. If TimeFormat is empty,
+	// time.RFC3339 is used for the formatted field.
+	DualTimestamp bool
+	// RedactFunc, when set, rewrites captured JSON request and response
+	// bodies before they are logged.
+	RedactFunc RedactFunc
+	// RedactBodyKeys lists keys of captured JSON bodies, matched
+	// case-insensitively at any depth, whose values are logged as "***". Their values are not passed to RedactFunc.
+	// The values of query parameters of the same names are logged as "***"
+	// in "query" and "request-uri".
+	RedactBodyKeys []string
+	// RedactHeaders lists headers, matched case-insensitively, whose values
+	// are logged as "***" wherever headers are logged.
+	RedactHeaders []string
+	// DedupWindow, when positive, collapses identical successive access logs
+	// (same method, path, status and client IP) seen within the window. The
+	// first line is written immediately; repeats are counted and written as a
+	// single line with a "repeat-count" field when the window expires or a
+	// different request is logged.
+	DedupWindow time.Duration
+	// LogTransferEncoding adds "chunked": true when the response was sent with
+	// chunked transfer encoding, either declared by the handler or chosen by
+	// net/http for an HTTP/1.1 body without a Content-Length that was flushed,
+	// or larger than net/http's 2KB buffer, before the handler returned.
+	LogTransferEncoding bool
+	// LogContentDisposition adds "content-disposition", the request's
+	// Content-Disposition header, which carries the file name of direct
+	// uploads. Omitted when the header is absent.
+	LogContentDisposition bool
+	// LogRequestMeta adds "content-length", the request's declared
+	// Content-Length, and "content-type", its Content-Type header, without
+	// capturing the body. Each is omitted when unknown or absent.
+	LogRequestMeta bool
+	// LogOrigin adds "origin", the request's Origin header, to help debug
+	// CORS rejections. Omitted when the header is absent.
+	LogOrigin bool
+	// LogForwardedFor adds "forwarded-for", the addresses of the
+	// X-Forwarded-For request headers as an array, omitted when absent, and
+	// "remote-addr", the TCP peer address, to compare the claimed proxy
+	// chain with the resolved "ip". Both are anonymized like "ip".
+	LogForwardedFor bool
+	// LogRequestHeaders adds "request-headers", an object mapping each
+	// request header to its values as an array.
+	LogRequestHeaders bool
+	// LogResponseHeaders adds "response-headers", like LogRequestHeaders for
+	// the headers of the response.
+	LogResponseHeaders bool
+	// LogCookies adds "cookies", an object mapping the name of each request
+	// cookie to "***", or to its value for the names in CookieValueAllowlist.
+	// Omitted when the request has no cookies.
+	LogCookies bool
+	// CookieValueAllowlist lists the cookie names, matched exactly, whose
+	// values LogCookies logs.
+	CookieValueAllowlist []string
+	// HeaderAllowlist restricts LogRequestHeaders and LogResponseHeaders to
+	// the listed headers, matched case-insensitively. RedactHeaders still
+	// applies to allowed headers.
+	HeaderAllowlist []string
+	// RequestHashFields lists the request parts ("method", "path", "query",
+	// "body") hashed into a deterministic "request-hash" field. The parts are
+	// always hashed in that order, each followed by a NUL byte, and the hash is
+	// the hex-encoded SHA-256 of the result. Unknown names are ignored.
+	// Hashing the body buffers it even when LogRequestBody is off.
+	RequestHashFields []string
+	// FirstLineErrorsOnly logs only the first line of each error message as
+	// the log message and moves the remaining lines to an "error-detail" field.
+	FirstLineErrorsOnly bool
+	// LogLatencyMicros adds "latency-us", the latency in whole microseconds
+	// (Apache %D).
+	LogLatencyMicros bool
+	// LatencyUnit, when positive, adds the latency as a number of this unit,
+	// e.g. time.Millisecond, under LatencyKey, so it can be graphed without
+	// parsing the "latency" duration.
+	LatencyUnit time.Duration
+	// LatencyAsFloat logs the LatencyUnit latency as a float instead of a
+	// truncated integer.
+	LatencyAsFloat bool
+	// LatencyKey is the field of the LatencyUnit latency. Optional, defaults
+	// to "latency-" followed by the unit symbol ("latency-ms" for
+	// milliseconds), or "latency-value" for other units.
+	LatencyKey string
+	// OmitLatencyDuration drops the "latency" field when LatencyUnit is set.
+	OmitLatencyDuration bool
+	// LogLatencySeconds adds "latency-sec", the latency rounded to whole
+	// seconds (Apache %T).
+	LogLatencySeconds bool
+	// LatencyBuckets adds "latency-bucket", a label of the smallest bucket
+	// the latency does not exceed, such as "le_100ms", or "gt_1s" past the
+	// largest, to aggregate lines into a histogram without computing
+	// buckets downstream. Optional, in any order.
+	LatencyBuckets []time.Duration
+	// LogBodyReadLatency adds "body-read-latency", the time spent buffering
+	// the request body for LogRequestBody or RequestHashFields before the
+	// handlers run. With slow clients this can dominate "latency", which by
+	// default spans the whole request including that read.
+	LogBodyReadLatency bool
+	// ExcludeBodyReadLatency subtracts the time spent buffering the request
+	// body from "latency" (and the fields derived from it), so it measures
+	// the handlers only.
+	ExcludeBodyReadLatency bool
+	// MaxConcurrentBodyCaptures limits how many requests may buffer request or
+	// response bodies at the same time. Requests arriving while the limit is
+	// reached are served normally but their bodies are not captured, and a
+	// "body-capture-skipped": "backpressure" field is logged instead. This
+	// trades body visibility for bounded memory under load. Zero means no limit.
+	MaxConcurrentBodyCaptures int
+	// LogStatusClass adds "status-class", the status grouped as "2xx", "3xx",
+	// etc., or "unknown" for codes outside 100-599.
+	LogStatusClass bool
+	// LogStatusText adds "status-text", the status reason phrase such as
+	// "Not Found". Omitted for codes without one.
+	LogStatusText bool
+	// LogRateLimitHeaders adds the rate-limit response headers set by the
+	// handlers: "ratelimit-limit", "ratelimit-remaining" and "ratelimit-reset"
+	// from X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+	// (or their unprefixed RateLimit-* forms), and "retry-after" from
+	// Retry-After. Absent headers are omitted.
+	LogRateLimitHeaders bool
+	// LogStreamStats adds "write-count", the number of writes the handlers
+	// made to the response, and "response-size", the bytes they wrote. The
+	// content is not buffered, so this suits streaming responses such as
+	// Server-Sent Events.
+	LogStreamStats bool
+	// LogResponseSize adds "response-size", the response body size in bytes
+	// as tracked by gin, 0 when nothing was written. It does not need body
+	// capture. LogStreamStats logs the same field.
+	LogResponseSize bool
+	// LogMaxFieldSize adds "max-field-size", the length in bytes of the
+	// longest string field of the line, usually a body, to find the requests
+	// bloating the logs without looking at their content.
+	LogMaxFieldSize bool
+	// LogCacheHeaders adds "etag", "cache-control" and "age" from the
+	// response headers. Absent headers are omitted.
+	LogCacheHeaders bool
+	// LogErrorBool adds "error": true when the request has entries in
+	// c.Errors or its status is at least ErrorStatusThreshold, and
+	// "error": false otherwise, independently of the log level.
+	LogErrorBool bool
+	// LogAborted adds "aborted": true or false, whether a handler called
+	// c.Abort, e.g. an auth or rate-limit middleware short-circuiting the
+	// request, or the recovery middleware after a panic.
+	LogAborted bool
+	// ErrorStatusThreshold is the lowest status counted as an error by
+	// LogErrorBool. Defaults to 500.
+	ErrorStatusThreshold int
+	// ErrorContext adds fields only to requests with entries in c.Errors. They
+	// are appended after the fields returned by Context.
+	ErrorContext Fn
+	// ClassifyBots adds "bot": true/false depending on whether the user agent
+	// contains a known automated-client substring (curl, wget,
+	// python-requests, common crawler tokens, ...). Matching is case-insensitive.
+	ClassifyBots bool
+	// BotUserAgents extends the built-in substrings used by ClassifyBots.
+	BotUserAgents []string
+	// HexDumpBodyMaxSize, when positive and LogRequestBody is on, logs request
+	// bodies of at most this many bytes as "request-body-hex", a hex dump of
+	// the raw bytes, instead of "request-body". Larger bodies are logged as usual.
+	HexDumpBodyMaxSize int
+	// LogQueryParamCount adds "query-param-count", the number of distinct
+	// query parameters, without their values. Omitted when there are none.
+	LogQueryParamCount bool
+	// LogQueryParamNames adds "query-param-names", the sorted names of the
+	// query parameters, without their values. Omitted when there are none.
+	LogQueryParamNames bool
+	// StructuredQuery logs "query" as an object of the parsed query
+	// parameters instead of the raw query string. Repeated parameters are
+	// logged as arrays, and the values of parameters named in RedactBodyKeys,
+	// compared case-insensitively, as "***".
+	StructuredQuery bool
+	// OmitEmptyQuery leaves out the "query" field of requests without a
+	// query string instead of logging "query": "".
+	OmitEmptyQuery bool
+	// DecodeQuery logs "query" with its names and values percent-decoded,
+	// e.g. "name=John Doe" for "name=John%20Doe", sorted by name. Queries
+	// that fail to parse are logged as-is. The values of parameters named in
+	// RedactBodyKeys are logged as "***" either way. StructuredQuery takes
+	// precedence.
+	DecodeQuery bool
+	// SanitizeUTF8 percent-escapes the bytes of "path", "query" and
+	// "user-agent" that are not valid UTF-8, such as those of scanner
+	// probes, e.g. "/a%FF" for "/a\xff", and adds "path-sanitized": true
+	// when any was escaped. A StructuredQuery is left as is. It does not apply
+	// to CaddyMode.
+	SanitizeUTF8 bool
+	// IncludeRuntimeStatsOnError adds "heap-alloc", "heap-inuse", "num-gc" and
+	// "goroutines" to requests with entries in c.Errors. Reading them calls
+	// runtime.ReadMemStats, which briefly stops the world, so it only happens
+	// on the error path; still, avoid it if errors are frequent.
+	IncludeRuntimeStatsOnError bool
+	// IncludeGoroutineID adds "goroutine", the id of the goroutine that
+	// served the request, to requests with entries in c.Errors or a recovered
+	// panic. The id is parsed from runtime.Stack, so it is best-effort: Go
+	// does not guarantee the format and the field is omitted if it changes.
+	IncludeGoroutineID bool
+	// DurationFormatter, when set, logs "latency" as the string it returns
+	// instead of as a zap.Duration.
+	DurationFormatter func(time.Duration) string
+	// LogBodyKeysOnly replaces the captured request and response bodies with
+	// "request-body-keys" and "response-body-keys", the top-level keys of a
+	// JSON object body in document order, so no values are logged. For other
+	// JSON bodies "request-body-type"/"response-body-type" is logged instead.
+	LogBodyKeysOnly bool
+	// EmitServerTimingHeader sets a "Server-Timing: app;dur=<ms>" response
+	// header with the time elapsed until the headers were sent. It only covers
+	// the time since this middleware started, not middleware registered
+	// before it, and it cannot see work done after the first body write.
+	EmitServerTimingHeader bool
+	// LogIdempotencyKey adds "idempotency-key" from the IdempotencyKeyHeader
+	// request header when present.
+	LogIdempotencyKey bool
+	// IdempotencyKeyHeader is the header read by LogIdempotencyKey.
+	// Optional, defaults to "Idempotency-Key".
+	IdempotencyKeyHeader string
+	// FieldNaming normalizes every built-in field name to one casing
+	// convention, e.g. FieldNamingSnake logs "user_agent" and "latency_us".
+	// Fields returned by Context and ErrorContext are not renamed.
+	FieldNaming FieldNaming
+	// FieldKeys renames built-in fields, keyed by their default name, e.g.
+	// {"method": "http.method", "status": "http.status_code"}. It takes
+	// precedence over FieldNaming and applies wherever FieldNaming does.
+	FieldKeys map[string]string
+	// LogStatic adds "static": true when the request was served by one of
+	// gin's static file handlers (Static, StaticFS, StaticFile, StaticFileFS).
+	LogStatic bool
+	// FeatureFlagsContextKey is the context key holding the active feature
+	// flags as a map[string]bool or a []string. When present they are logged
+	// as "feature-flags"; values of other types are ignored.
+	FeatureFlagsContextKey string
+	// LogRequestStart writes an additional Debug level "request started" line
+	// with the method, path and request id (from RequestIDHeader, or else
+	// X-Request-Id) before the handlers run, which helps to spot requests that
+	// never complete. It requires a logger with a Debug method, such as
+	// *zap.Logger, and honors SkipPaths and SkipMethods.
+	LogRequestStart bool
+	// ServiceName, when set, is logged as "service" on every line.
+	ServiceName string
+	// IncludeHostname adds "host", the hostname of the machine, resolved once
+	// when the middleware is created.
+	IncludeHostname bool
+	// RequestIDHeader is a request header, such as "X-Request-Id", logged as
+	// "request-id", set back on the response and returned by
+	// RequestIDFromContext. Optional.
+	RequestIDHeader string
+	// GenerateRequestID generates a UUID when the RequestIDHeader is absent.
+	GenerateRequestID bool
+	// CorrelationHeaders are the request headers consulted, in order, for a
+	// correlation id, e.g. X-Correlation-Id, X-Request-Id and X-Trace-Id for
+	// upstreams that disagree on the name. The first non-empty value is
+	// logged as "correlation-id" and returned by CorrelationID.
+	CorrelationHeaders []string
+	// EchoCorrelationID sets the correlation id on the response under the
+	// first of the CorrelationHeaders.
+	EchoCorrelationID bool
+	// GenerateCorrelationID generates a random correlation id when none of
+	// the CorrelationHeaders is present.
+	GenerateCorrelationID bool
+	// UpstreamTimingHeader is a response header in which a proxied upstream
+	// reports its processing time, either as a Go duration ("12.5ms") or as a
+	// bare number of milliseconds (as in x-envoy-upstream-service-time). When
+	// present and valid it is logged as "upstream-latency", together with
+	// "proxy-overhead", the measured latency minus the upstream latency.
+	UpstreamTimingHeader string
+	// BodyLoggingOptIn only logs the bodies captured by LogRequestBody and
+	// LogResponseBody for requests whose handler called LogBody. Bodies are
+	// still buffered for every request, since the middleware cannot know up
+	// front which handlers will opt in.
+	BodyLoggingOptIn bool
+	// ShouldLogRequestBody, when set, is called before the handlers run and
+	// restricts LogRequestBody to the requests it returns true for, e.g. to
+	// skip a bulk import endpoint.
+	ShouldLogRequestBody func(c *gin.Context) bool
+	// ShouldLogResponseBody does the same as ShouldLogRequestBody for
+	// LogResponseBody.
+	ShouldLogResponseBody func(c *gin.Context) bool
+	// LogMultipartMetadata adds "multipart-parts" for multipart/form-data
+	// requests: the form name, file name, content type and size of every
+	// part, but never their content, in place of "request-body". The body is
+	// buffered in memory, up to MaxRequestBodySize, and restored for the
+	// handlers; when it does not fit, "multipart-truncated": true is added.
+	LogMultipartMetadata bool
+	// BodyOnError only logs captured bodies for requests with errors in
+	// c.Errors or a status of 400 or more. Bodies are still buffered for
+	// every request.
+	BodyOnError bool
+	// BodyOnStatusCodes only logs captured bodies for requests with one of
+	// these statuses, or with errors in c.Errors. Combined with BodyOnError,
+	// bodies are logged when either matches.
+	BodyOnStatusCodes []int
+	// TailSample captures the request and response bodies and headers of
+	// every request, but only logs them, as LogRequestBody, LogResponseBody,
+	// LogRequestHeaders and LogResponseHeaders would, for troubled requests:
+	// those with errors in c.Errors, a status of at least
+	// ErrorStatusThreshold, a recovered panic or a latency above
+	// SlowThreshold. Other requests get the basic line. The options it
+	// combines still apply on their own.
+	TailSample bool
+	// LogResponseFieldCount adds "response-field-count", the number of keys
+	// of a JSON object response or the number of elements of a JSON array
+	// response. It buffers the response like LogResponseBody, without logging
+	// it. Omitted for any other response.
+	LogResponseFieldCount bool
+	// TimeFormats maps field names to time layouts; each entry logs the end
+	// time of the request under that name, e.g. {"date": "2006-01-02"}. An
+	// entry for "time" overrides TimeFormat. Extra fields are logged sorted by
+	// name and respect UTC.
+	TimeFormats map[string]string
+	// TimeFormatFunc, when set, picks the layout of the "time" field per
+	// request in place of TimeFormat, e.g. time.RFC3339Nano for audit
+	// endpoints only. An empty layout omits the field, as an empty
+	// TimeFormat does.
+	TimeFormatFunc func(c *gin.Context) string
+	// LogEndTime adds "request-end", the instant the request finished, as a
+	// time field encoded by the logger, independently of TimeFormat. It can
+	// differ from the entry's own timestamp, the write time, e.g. with Async.
+	// It respects UTC.
+	LogEndTime bool
+	// APIVersionFunc returns the API version of the request, logged as
+	// "api-version" when non-empty. PathAPIVersion handles path-based
+	// versioning such as /v2/users.
+	APIVersionFunc func(c *gin.Context) string
+	// IncludeAllocStats adds "allocs", the number of heap allocations made
+	// while the handlers ran. It calls runtime.ReadMemStats, which stops the
+	// world, twice per request, and the counter is process-wide so concurrent
+	// requests inflate each other's numbers. Use it for profiling in
+	// non-production environments only.
+	IncludeAllocStats bool
+	// FieldOrder lists field names (as logged, after FieldNaming and
+	// FieldKeys) in the order they should be emitted. Unlisted fields follow
+	// in their natural order.
+	FieldOrder []string
+	// CostContextKey is the context key holding the request cost computed by
+	// the handlers, as an integer or floating-point number. When present it is
+	// logged as "cost"; values of other types are ignored.
+	CostContextKey string
+	// CaddyMode replaces the standard status, method, path, query, ip,
+	// user-agent and latency fields with the layout of Caddy's JSON access
+	// logs, so existing Caddy dashboards keep working: a "request" object
+	// (remote_ip, remote_port, client_ip, proto, method, host, uri, headers),
+	// "bytes_read", "duration" in seconds, "size", "status" and
+	// "resp_headers". Successful requests use Caddy's "handled request"
+	// message. These fields keep their Caddy names regardless of FieldNaming.
+	CaddyMode bool
+	// LoggerFunc, when set, picks the logger of each request, e.g. a
+	// dedicated audit logger for /admin routes. A nil result falls back to
+	// the logger passed to GinzapWithConfig. It is called before the
+	// handlers run.
+	LoggerFunc func(c *gin.Context) ZapLogger
+	// SuccessMessage is the message of the lines of requests without errors.
+	// With a LevelLogger such as *zap.Logger it defaults to "" (or Caddy's
+	// message in CaddyMode); with other ZapLogger implementations, which are
+	// logged through Info or Error, it defaults to the request path.
+	SuccessMessage string
+	// ErrorMessage, when set, is the message of the lines of errors in
+	// c.Errors, so they group under one message, and the error text moves
+	// to an "error" field, which then replaces the "error" of LogErrorBool.
+	// By default the message is the error text.
+	ErrorMessage string
+	// AggregateErrors logs a request with errors in c.Errors as one Error
+	// level line, whose message is the first error, with an "errors" field
+	// listing every error as an object with its "message", gin error "type"
+	// and, when set, its "meta". By default one line per error is written.
+	AggregateErrors bool
+	// ErrorLevelFunc, when set, chooses the level of the line of each error in
+	// c.Errors from its gin error type and the response status, e.g. Warn for
+	// gin.ErrorTypeBind. With AggregateErrors the highest level wins. Loggers
+	// not implementing LevelLogger write Info as Info and other levels as
+	// Error. By default errors are logged at Error.
+	ErrorLevelFunc func(errType gin.ErrorType, status int) zapcore.Level
+	// ClientIPFunc, when set, replaces c.ClientIP() as the source of the
+	// logged client IP, e.g. to read CF-Connecting-IP behind a CDN without
+	// changing gin's trusted proxies. Optional.
+	ClientIPFunc func(c *gin.Context) string
+	// AnonymizeIP masks client IPs before they are logged, zeroing the last
+	// octet of IPv4 addresses and the last 80 bits of IPv6 addresses.
+	AnonymizeIP bool
+	// ConnReusedContextKey is the context key telling whether the request
+	// arrived on a reused keep-alive connection, logged as "conn-reused". It
+	// is read when the request starts and may hold a bool, or the counter
+	// installed by TrackConnReuse as http.Server.ConnContext, which is the
+	// wiring needed since net/http does not expose connection reuse to
+	// handlers.
+	ConnReusedContextKey string
+	// LogNonce adds "log-nonce" to every line: a sequence number that
+	// increases by one per line, followed by random bytes, e.g.
+	// "42-9f86d081884c7d65". Gaps in the sequence reveal deleted lines, but
+	// anyone can forge a nonce; use LogChainSecret for tamper evidence.
+	LogNonce bool
+	// LogChainSecret, when set, adds "log-seq" and "log-chain" to every line,
+	// linking the lines into a hash chain: log-chain is LogChainMAC of the
+	// secret, log-seq and the previous line's log-chain. Without the secret,
+	// lines cannot be removed, reordered or inserted without breaking the
+	// chain. The chain only covers the sequence, not the other fields, so it
+	// does not detect edits within a line, and it restarts when the process
+	// does. Concurrent requests may be written slightly out of order; sort by
+	// log-seq before verifying.
+	LogChainSecret []byte
+	// BodyBatcher, when set, moves captured request and response bodies out
+	// of the access log into a separate stream: the access log line carries
+	// "body-ref" and the bodies are encoded and logged later by the batcher,
+	// under the same "body-ref". See BodyBatcher for the loss semantics.
+	BodyBatcher *BodyBatcher
+	// Async, when set, writes the access log lines from the AsyncWriter's
+	// goroutine instead of the request's, so a slow sink does not delay
+	// responses. See AsyncWriter for the loss semantics.
+	Async *AsyncWriter
+	// TraceContext adds "trace_id" and "span_id" from the OpenTelemetry span
+	// context of the request, when there is a valid one; see OtelContext.
+	// These names are not affected by FieldNaming.
+	TraceContext bool
+	// Profile is the profile, ProfileDev, ProfileProd or ProfileTest, whose
+	// defaults the Config holds. Prefer NewConfig, which sets them as plain
+	// fields that can each be overridden. Set on a Config literal, the
+	// defaults only fill fields left at their zero value, so they cannot be
+	// turned back off. See the Profile constants for what each one sets.
+	Profile Profile
+	// profileApplied is set by NewConfig, whose fields already hold the
+	// defaults of Profile.
+	profileApplied bool
+}
+
+// timeField is an extra formatted time field from Config.TimeFormats.
+type timeField struct {
+	key    string
+	layout string
 }
 
 // Ginzap returns a gin.HandlerFunc (middleware) that logs requests using uber-go/zap.
 //
 // Requests with errors are logged using zap.Error().
 // Requests without errors are logged using zap.Info().
+// Requests whose context was canceled or timed out carry "context-error".
 //
 // It receives:
 //  1. A time package format string (e.g. time.RFC3339).
@@ -54,140 +686,1207 @@ func Ginzap(logger ZapLogger, timeFormat string, utc bool) gin.HandlerFunc {
 }
 
 // GinzapWithConfig returns a gin.HandlerFunc using configs
+//
+// New code should prefer New, which takes options instead of a Config.
+//
+// A panic while building or writing a line, e.g. in Context, is recovered and
+// logged at Error as "ginzap internal panic" with the status, method and path.
 func GinzapWithConfig(logger ZapLogger, conf *Config) gin.HandlerFunc {
+	return accessLog(logger, conf, (*gin.Context).Next)
+}
+
+// accessLog returns the access logging middleware, running the rest of the
+// chain with next.
+func accessLog(logger ZapLogger, conf *Config, next gin.HandlerFunc) gin.HandlerFunc {
+	conf = conf.withProfile()
+
 	skipPaths := make(map[string]bool, len(conf.SkipPaths))
 	for _, path := range conf.SkipPaths {
 		skipPaths[path] = true
 	}
 
+	skipMethods := make(map[string]bool, len(conf.SkipMethods))
+	for _, method := range conf.SkipMethods {
+		skipMethods[strings.ToUpper(method)] = true
+	}
+
+	bodyStatuses := make(map[int]bool, len(conf.BodyOnStatusCodes))
+	for _, status := range conf.BodyOnStatusCodes {
+		bodyStatuses[status] = true
+	}
+
+	skipStatuses := make(map[int]bool, len(conf.SkipStatusCodes))
+	for _, status := range conf.SkipStatusCodes {
+		skipStatuses[status] = true
+	}
+
+	now := conf.clock()
+
+	names := newFieldNamer(conf.FieldNaming, conf.FieldKeys)
+	maskPath := newPathMasker(conf)
+
+	successMessage := conf.SuccessMessage
+	if successMessage == "" && conf.CaddyMode {
+		successMessage = caddyMessage
+	}
+
+	var fieldRank map[string]int
+	if len(conf.FieldOrder) > 0 {
+		fieldRank = make(map[string]int, len(conf.FieldOrder))
+		for i, key := range conf.FieldOrder {
+			if _, ok := fieldRank[key]; !ok {
+				fieldRank[key] = i
+			}
+		}
+	}
+
+	timeFormat := conf.TimeFormat
+	var timeFields []timeField
+	for key, layout := range conf.TimeFormats {
+		if key == "time" {
+			timeFormat = layout
+			continue
+		}
+		timeFields = append(timeFields, timeField{key: key, layout: layout})
+	}
+	sort.Slice(timeFields, func(i, j int) bool { return timeFields[i].key < timeFields[j].key })
+
+	latencyKey := conf.LatencyKey
+	buckets := sortedBuckets(conf.LatencyBuckets)
+	if latencyKey == "" {
+		latencyKey = "latency-" + unitSymbol(conf.LatencyUnit)
+	}
+
+	hashParts := make(map[string]bool, len(conf.RequestHashFields))
+	for _, part := range conf.RequestHashFields {
+		hashParts[part] = true
+	}
+
+	var bodySem chan struct{}
+	if conf.MaxConcurrentBodyCaptures > 0 {
+		bodySem = make(chan struct{}, conf.MaxConcurrentBodyCaptures)
+	}
+
+	errorThreshold := conf.ErrorStatusThreshold
+	if errorThreshold <= 0 {
+		errorThreshold = http.StatusInternalServerError
+	}
+
+	var staticFields []zapcore.Field
+	if conf.ServiceName != "" {
+		staticFields = append(staticFields, zap.String("service", conf.ServiceName))
+	}
+	if conf.IncludeHostname {
+		if host, err := os.Hostname(); err == nil {
+			staticFields = append(staticFields, zap.String("host", host))
+		}
+	}
+
+	var sampler *rateSampler
+	if conf.SampleRate > 0 && conf.SampleRate < 1 {
+		sampler = newRateSampler(conf.SampleRate)
+	}
+
+	idempotencyKeyHeader := conf.IdempotencyKeyHeader
+	if idempotencyKeyHeader == "" {
+		idempotencyKeyHeader = "Idempotency-Key"
+	}
+
+	var bots botMatcher
+	if conf.ClassifyBots {
+		bots = newBotMatcher(conf.BotUserAgents)
+	}
+
+	var nonces *nonceSource
+	if conf.LogNonce {
+		nonces = &nonceSource{}
+	}
+	var chain *logChain
+	if len(conf.LogChainSecret) > 0 {
+		chain = &logChain{secret: conf.LogChainSecret}
+	}
+	// seal appends the per-line nonce and chain fields, if any.
+	seal := func(fields []zapcore.Field) []zapcore.Field {
+		if nonces == nil && chain == nil {
+			return fields
+		}
+		fields = fields[:len(fields):len(fields)]
+		n := len(fields)
+		if nonces != nil {
+			fields = append(fields, nonces.next())
+		}
+		if chain != nil {
+			fields = append(fields, chain.next()...)
+		}
+		names.rename(fields[n:])
+		return fields
+	}
+
+	var dedup *deduper
+	if conf.DedupWindow > 0 {
+		dedup = &deduper{window: conf.DedupWindow, countKey: names.key("repeat-count"), now: now}
+	}
+
 	return func(c *gin.Context) {
-		start := time.Now()
+		start := now()
+		if conf.Clock != nil {
+			c.Set(clockKey, now)
+		}
+		logger := logger
+		if conf.LoggerFunc != nil {
+			if l := conf.LoggerFunc(c); l != nil {
+				logger = l
+			}
+		}
 		// some evil middlewares modify this values
 		path := c.Request.URL.Path
 		query := c.Request.URL.RawQuery
-		c.Next()
+		loggedPath := path
+		if maskPath != nil {
+			loggedPath = maskPath(path)
+		}
+
+		requestID := resolveRequestID(c, conf)
+		correlationID := resolveCorrelationID(c, conf)
+
+		// Read before c.Next so connection counters see skipped requests too.
+		var connReuse, connReuseOK bool
+		if conf.ConnReusedContextKey != "" {
+			if v, ok := contextValue(c, conf.ConnReusedContextKey); ok {
+				connReuse, connReuseOK = connReused(v)
+			}
+		}
+
+		var stw *serverTimingWriter
+		if conf.EmitServerTimingHeader {
+			stw = &serverTimingWriter{ResponseWriter: c.Writer, start: start, now: now}
+			c.Writer = stw
+		}
+
+		// SkipPaths and SkipMethods are known before the handlers run, so
+		// those requests skip body capture and the other writer wrappers.
+		if skipPaths[path] || (len(skipMethods) > 0 && skipMethods[strings.ToUpper(c.Request.Method)]) {
+			next(c)
+			if stw != nil {
+				stw.setHeader()
+			}
+			return
+		}
+
+		if conf.LogRequestStart {
+			if dl, ok := logger.(debugLogger); ok {
+				startFields := []zapcore.Field{
+					zap.String(names.key("method"), c.Request.Method),
+					zap.String(names.key("path"), loggedPath),
+				}
+				id := requestID
+				if conf.RequestIDHeader == "" {
+					id = c.GetHeader("X-Request-Id")
+				}
+				if id != "" {
+					startFields = append(startFields, zap.String(names.key("request-id"), id))
+				}
+				if correlationID != "" {
+					startFields = append(startFields, zap.String(names.key("correlation-id"), correlationID))
+				}
+				dl.Debug("request started", startFields...)
+			}
+		}
+
+		var boundary string
+		if conf.LogMultipartMetadata {
+			boundary = multipartBoundary(c.Request)
+		}
+
+		// The predicates are evaluated before the handlers, since bodies
+		// cannot be captured after the fact.
+		logRequestBody := (conf.LogRequestBody || conf.TailSample) && (conf.ShouldLogRequestBody == nil || conf.ShouldLogRequestBody(c))
+		logResponseBody := (conf.LogResponseBody || conf.TailSample) && (conf.ShouldLogResponseBody == nil || conf.ShouldLogResponseBody(c))
+		captureRequest := logRequestBody || hashParts["body"]
+		captureResponse := logResponseBody || conf.LogResponseFieldCount
+
+		capture := captureRequest || captureResponse || boundary != ""
+		var captureSkipped bool
+		if capture && bodySem != nil {
+			select {
+			case bodySem <- struct{}{}:
+				defer func() { <-bodySem }()
+			default:
+				capture, captureSkipped = false, true
+			}
+		}
+
+		var requestBody []byte
+		var requestTruncated bool
+		var requestReadErr error
+		var bodyReadLatency time.Duration
+		if capture && (captureRequest || boundary != "") {
+			readStart := now()
+			requestBuf := getBuffer()
+			var shared bool
+			requestBody, requestTruncated, shared, requestReadErr = captureRequestBody(c, requestBuf, conf.MaxRequestBodySize)
+			if !shared {
+				// Like the response buffer, it is free once the line is written.
+				defer putBuffer(requestBuf)
+			}
+			bodyReadLatency = now().Sub(readStart)
+		}
+
+		var counter *countingBody
+		if conf.CountRequestBody && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			counter = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = counter
+		}
+
+		var blw *bodyLogWriter
+		if capture && captureResponse {
+			blw = &bodyLogWriter{body: getBuffer(), ResponseWriter: c.Writer, limit: conf.MaxResponseBodySize, contentTypes: conf.ResponseBodyContentTypes}
+			// Logged fields copy the body, so the buffer is free once the
+			// line is written.
+			defer putBuffer(blw.body)
+			c.Writer = blw
+			c.Set(responseBodyKey, blw)
+		}
+
+		var tw *transferWriter
+		if conf.LogTransferEncoding {
+			tw = &transferWriter{ResponseWriter: c.Writer}
+			c.Writer = tw
+		}
+
+		var ssw *streamStatsWriter
+		if conf.LogStreamStats {
+			ssw = &streamStatsWriter{ResponseWriter: c.Writer}
+			c.Writer = ssw
+		}
+
+		var mallocs uint64
+		if conf.IncludeAllocStats {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			mallocs = m.Mallocs
+		}
+
+		next(c)
+
+		if conf.IncludeAllocStats {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			mallocs = m.Mallocs - mallocs
+		}
+
+		if stw != nil {
+			// gin writes the headers of empty responses after the handlers
+			// return, bypassing the wrapped writer.
+			stw.setHeader()
+		}
+
 		track := true
 
-		if _, ok := skipPaths[path]; ok || (conf.Skipper != nil && conf.Skipper(c)) {
+		if conf.Skipper != nil && conf.Skipper(c) {
 			track = false
 		}
 
-		if track && len(conf.SkipPathRegexps) > 0 {
-			for _, reg := range conf.SkipPathRegexps {
-				if !reg.MatchString(path) {
-					continue
-				}
+		if c.GetBool(skipLoggingKey) {
+			track = false
+		}
 
+		if track && len(c.Errors) == 0 {
+			status := c.Writer.Status()
+			if skipStatuses[status] || (conf.StatusSkipper != nil && conf.StatusSkipper(status)) {
 				track = false
-				break
 			}
 		}
 
+		if track && shouldSkipByRegexp(path, conf.SkipPathRegexps) {
+			track = false
+		}
+
+		if track && conf.LogOnlyWhen != nil && !conf.LogOnlyWhen(c) {
+			track = len(c.Errors) > 0 || c.Writer.Status() >= errorThreshold
+		}
+
+		if track && sampler != nil && len(c.Errors) == 0 && c.Writer.Status() < errorThreshold {
+			track = sampler.sample()
+		}
+
 		if track {
-			end := time.Now()
-			latency := end.Sub(start)
+			// A panic while assembling or writing the line, e.g. in a
+			// Context Fn, must not fail the request: it is logged with the
+			// basic fields instead.
+			defer func() {
+				if err := recover(); err != nil {
+					logger.Error("ginzap internal panic",
+						zap.Any("error", err),
+						zap.Int("status", c.Writer.Status()),
+						zap.String("method", c.Request.Method),
+						zap.String("path", loggedPath),
+					)
+				}
+			}()
+			finished := now()
+			latency := finished.Sub(start)
+			if conf.ExcludeBodyReadLatency {
+				latency -= bodyReadLatency
+			}
+			// end only stamps the line: UTC drops the monotonic clock
+			// reading durations are measured with.
+			end := finished
 			if conf.UTC {
 				end = end.UTC()
 			}
+			if conf.Observer != nil {
+				conf.Observer.Observe(c.Request.Method, c.FullPath(), c.Writer.Status(), latency)
+			}
+
+			var fields []zapcore.Field
+			// pooled holds the fields slice when it returns to the pool after
+			// the line is written. The deduper and Async keep lines past write,
+			// and only loggers known to copy fields may be handed a pooled one.
+			var pooled *[]zapcore.Field
+			// renameFrom is the index of the first field subject to FieldNaming.
+			var renameFrom int
+			if conf.CaddyMode {
+				var maskedPath string
+				if maskPath != nil {
+					maskedPath = loggedPath
+				}
+				fields = caddyFields(c, conf, maskedPath, latency)
+				renameFrom = len(fields)
+			} else {
+				if dedup == nil && conf.Async == nil && copiesFields(logger) {
+					pooled = getFields()
+					fields = *pooled
+				} else {
+					fields = make([]zapcore.Field, 0, pooledFieldsCap)
+				}
+				fieldPath, userAgent := loggedPath, c.Request.UserAgent()
+				var sanitized bool
+				if conf.SanitizeUTF8 {
+					var pathChanged, userAgentChanged bool
+					fieldPath, pathChanged = sanitizeUTF8(fieldPath)
+					userAgent, userAgentChanged = sanitizeUTF8(userAgent)
+					sanitized = pathChanged || userAgentChanged
+				}
+				fields = append(fields,
+					zap.Int("status", c.Writer.Status()),
+					zap.String("method", c.Request.Method),
+					zap.String("path", fieldPath),
+				)
+				if query != "" || !conf.OmitEmptyQuery {
+					field, changed := queryField(conf, query)
+					fields = append(fields, field)
+					sanitized = sanitized || changed
+				}
+				fields = append(fields,
+					zap.String("ip", clientIP(c, conf)),
+					zap.String("user-agent", userAgent),
+				)
+				if sanitized {
+					fields = append(fields, zap.Bool("path-sanitized", true))
+				}
+				switch {
+				case conf.OmitLatencyDuration && conf.LatencyUnit > 0:
+					// replaced by the LatencyUnit field
+				case conf.DurationFormatter != nil:
+					fields = append(fields, zap.String("latency", conf.DurationFormatter(latency)))
+				default:
+					fields = append(fields, zap.Duration("latency", latency))
+				}
+			}
+			if conf.FlagEmptyResponses && isEmptyResponse(c) {
+				fields = append(fields, zap.Bool("empty-response", true))
+			}
+			nonstandardMethod := conf.FlagNonstandardMethods && !isStandardMethod(c.Request.Method)
+			if nonstandardMethod {
+				fields = append(fields, zap.Bool("method-nonstandard", true))
+			}
+			if conf.UseFullPath {
+				route := c.FullPath()
+				if route == "" {
+					route = loggedPath
+				}
+				fields = append(fields, zap.String("route", route))
+			}
+			if conf.LogHandlerName {
+				fields = append(fields, zap.String("handler", c.HandlerName()))
+			}
+			if conf.LogProtocol {
+				fields = append(fields, zap.String("proto", c.Request.Proto))
+			}
+			if conf.LogRequestURI {
+				fields = append(fields, zap.String("request-uri", requestURI(c.Request.RequestURI, maskPath, conf.RedactBodyKeys)))
+			}
+			if conf.LogQueryParamCount || conf.LogQueryParamNames {
+				if params := c.Request.URL.Query(); len(params) > 0 {
+					if conf.LogQueryParamCount {
+						fields = append(fields, zap.Int("query-param-count", len(params)))
+					}
+					if conf.LogQueryParamNames {
+						paramNames := make([]string, 0, len(params))
+						for name := range params {
+							paramNames = append(paramNames, name)
+						}
+						sort.Strings(paramNames)
+						fields = append(fields, zap.Strings("query-param-names", paramNames))
+					}
+				}
+			}
+			fields = append(fields, staticFields...)
+			if requestID == "" {
+				// A recovery middleware with LogRequestID may have generated one.
+				requestID = RequestIDFromContext(c)
+			}
+			if requestID != "" {
+				fields = append(fields, zap.String("request-id", requestID))
+			}
+			if correlationID != "" {
+				fields = append(fields, zap.String("correlation-id", correlationID))
+			}
+			if conf.LogTLS && c.Request.TLS != nil {
+				fields = append(fields, tlsFields(c.Request.TLS)...)
+			}
+			if conf.LogIdempotencyKey {
+				if key := c.GetHeader(idempotencyKeyHeader); key != "" {
+					fields = append(fields, zap.String("idempotency-key", key))
+				}
+			}
+			if conf.IncludeAllocStats {
+				fields = append(fields, zap.Uint64("allocs", mallocs))
+			}
+			if conf.APIVersionFunc != nil {
+				if version := conf.APIVersionFunc(c); version != "" {
+					fields = append(fields, zap.String("api-version", version))
+				}
+			}
+			if conf.LogStatic && isStaticHandler(c.HandlerName()) {
+				fields = append(fields, zap.Bool("static", true))
+			}
+			if bots != nil {
+				fields = append(fields, zap.Bool("bot", bots.match(c.Request.UserAgent())))
+			}
+			if conf.UpstreamTimingHeader != "" {
+				if upstream, ok := parseUpstreamTiming(c.Writer.Header().Get(conf.UpstreamTimingHeader)); ok {
+					fields = append(fields,
+						zap.Duration("upstream-latency", upstream),
+						zap.Duration("proxy-overhead", latency-upstream),
+					)
+				}
+			}
+			if conf.LogStatusClass {
+				fields = append(fields, zap.String("status-class", statusClass(c.Writer.Status())))
+			}
+			if conf.LogStatusText {
+				if text := http.StatusText(c.Writer.Status()); text != "" {
+					fields = append(fields, zap.String("status-text", text))
+				}
+			}
+			if conf.LogErrorBool && (conf.ErrorMessage == "" || len(c.Errors) == 0) {
+				fields = append(fields, zap.Bool("error", len(c.Errors) > 0 || c.Writer.Status() >= errorThreshold))
+			}
+			if conf.LogAborted {
+				fields = append(fields, zap.Bool("aborted", c.IsAborted()))
+			}
+			// A handler that returned because its context was canceled or
+			// timed out, e.g. by a timeout middleware, says so here.
+			if err := c.Request.Context().Err(); err != nil {
+				fields = append(fields, zap.String("context-error", err.Error()))
+			}
+			if ssw != nil {
+				fields = append(fields, zap.Int("write-count", ssw.writes), zap.Int64("response-size", ssw.bytes))
+			} else if conf.LogResponseSize {
+				size := c.Writer.Size()
+				if size < 0 {
+					size = 0
+				}
+				fields = append(fields, zap.Int("response-size", size))
+			}
+			if conf.LogRateLimitHeaders {
+				fields = append(fields, rateLimitFields(c.Writer.Header())...)
+			}
+			if conf.LogCacheHeaders {
+				fields = append(fields, cacheFields(c.Writer.Header())...)
+			}
+			if conf.LogBodyReadLatency && capture && captureRequest {
+				fields = append(fields, zap.Duration("body-read-latency", bodyReadLatency))
+			}
+			if conf.LatencyUnit > 0 {
+				if conf.LatencyAsFloat {
+					fields = append(fields, zap.Float64(latencyKey, float64(latency)/float64(conf.LatencyUnit)))
+				} else {
+					fields = append(fields, zap.Int64(latencyKey, int64(latency/conf.LatencyUnit)))
+				}
+			}
+			if v, ok := c.Get(handlerStartKey); ok {
+				if mark, ok := v.(time.Time); ok {
+					fields = append(fields, zap.Duration("handler-latency", finished.Sub(mark)))
+				}
+			}
+			if len(buckets) > 0 {
+				fields = append(fields, zap.String("latency-bucket", latencyBucket(latency, buckets)))
+			}
+			if conf.LogLatencyMicros {
+				fields = append(fields, zap.Int64("latency-us", int64(latency/time.Microsecond)))
+			}
+			if conf.LogLatencySeconds {
+				fields = append(fields, zap.Int64("latency-sec", int64(latency.Round(time.Second)/time.Second)))
+			}
+			layout := timeFormat
+			if conf.TimeFormatFunc != nil {
+				layout = conf.TimeFormatFunc(c)
+			}
+			if layout != "" {
+				fields = append(fields, zap.String("time", end.Format(layout)))
+			} else if conf.DualTimestamp {
+				fields = append(fields, zap.String("time", end.Format(time.RFC3339)))
+			}
+			for _, tf := range timeFields {
+				fields = append(fields, zap.String(tf.key, end.Format(tf.layout)))
+			}
+			if conf.DualTimestamp {
+				fields = append(fields, zap.Int64("ts", end.UnixNano()/int64(time.Millisecond)))
+			}
+			if conf.LogEndTime {
+				fields = append(fields, zap.Time("request-end", end))
+			}
+
+			if conf.QueueStartContextKey != "" {
+				if v, ok := contextValue(c, conf.QueueStartContextKey); ok {
+					if queued, ok := v.(time.Time); ok {
+						fields = append(fields, zap.Duration("queue-wait", start.Sub(queued)))
+					}
+				}
+			}
+
+			if conf.StreamIDContextKey != "" {
+				if v, ok := contextValue(c, conf.StreamIDContextKey); ok {
+					fields = append(fields, zap.Any("stream-id", v))
+				}
+			}
 
-			fields := []zapcore.Field{
-				zap.Int("status", c.Writer.Status()),
-				zap.String("method", c.Request.Method),
-				zap.String("path", path),
-				zap.String("query", query),
-				zap.String("ip", c.ClientIP()),
-				zap.String("user-agent", c.Request.UserAgent()),
-				zap.Duration("latency", latency),
+			if conf.PrincipalTypeContextKey != "" {
+				if v, ok := contextValue(c, conf.PrincipalTypeContextKey); ok {
+					fields = append(fields, zap.Any("principal-type", v))
+				}
 			}
-			if conf.TimeFormat != "" {
-				fields = append(fields, zap.String("time", end.Format(conf.TimeFormat)))
+
+			if conf.FeatureFlagsContextKey != "" {
+				if v, ok := contextValue(c, conf.FeatureFlagsContextKey); ok {
+					switch flags := v.(type) {
+					case map[string]bool:
+						fields = append(fields, zap.Any("feature-flags", flags))
+					case []string:
+						fields = append(fields, zap.Strings("feature-flags", flags))
+					}
+				}
+			}
+
+			if conf.CostContextKey != "" {
+				if v, ok := contextValue(c, conf.CostContextKey); ok {
+					if field, ok := numberField("cost", v); ok {
+						fields = append(fields, field)
+					}
+				}
+			}
+
+			if connReuseOK {
+				fields = append(fields, zap.Bool("conn-reused", connReuse))
+			}
+
+			if n := internalForwards(c); n > 0 {
+				fields = append(fields, zap.Int("internal-forwards", n))
+			}
+
+			if conf.LogValidationErrors {
+				if verrs := validationErrors(c.Errors, conf.RedactBodyKeys); len(verrs) > 0 {
+					fields = append(fields, zap.Array("validation-errors", verrs))
+				}
+			}
+
+			if tw != nil && isChunked(c.Request, c.Writer, tw.flushed) {
+				fields = append(fields, zap.Bool("chunked", true))
+			}
+
+			if conf.LogForwardedFor {
+				if addrs := forwardedFor(c.Request.Header, conf.AnonymizeIP); len(addrs) > 0 {
+					fields = append(fields, zap.Strings("forwarded-for", addrs))
+				}
+				fields = append(fields, zap.String("remote-addr", remoteAddr(c.Request, conf.AnonymizeIP)))
+			}
+
+			if conf.LogOrigin {
+				if origin := c.Request.Header.Get("Origin"); origin != "" {
+					fields = append(fields, zap.String("origin", origin))
+				}
+			}
+
+			// troubled requests get the verbose fields of TailSample.
+			troubled := conf.TailSample && (len(c.Errors) > 0 || c.Writer.Status() >= errorThreshold || c.GetBool(panicKey) ||
+				conf.SlowThreshold > 0 && latency > conf.SlowThreshold)
+			if conf.LogRequestHeaders || troubled {
+				h := redactHeader(allowHeaders(c.Request.Header, conf.HeaderAllowlist), conf.RedactHeaders)
+				fields = append(fields, zap.Object("request-headers", headerObject(h)))
+			}
+			if conf.LogResponseHeaders || troubled {
+				h := redactHeader(allowHeaders(c.Writer.Header(), conf.HeaderAllowlist), conf.RedactHeaders)
+				fields = append(fields, zap.Object("response-headers", headerObject(h)))
+			}
+
+			if conf.LogCookies {
+				if cookies := c.Request.Cookies(); len(cookies) > 0 {
+					fields = append(fields, zap.Object("cookies", cookieObject{cookies: cookies, allow: conf.CookieValueAllowlist}))
+				}
+			}
+
+			if conf.LogContentDisposition {
+				if cd := c.Request.Header.Get("Content-Disposition"); cd != "" {
+					fields = append(fields, zap.String("content-disposition", cd))
+				}
+			}
+
+			if conf.LogRequestBodySize && capture && captureRequest && requestBody != nil && requestReadErr == nil && !requestTruncated {
+				fields = append(fields, zap.Int("request-body-size", len(requestBody)))
+			}
+			if conf.CountRequestBody {
+				var read int64
+				if counter != nil {
+					read = counter.n
+				}
+				fields = append(fields, zap.Int64("request-body-read", read))
+			}
+			if conf.LogRequestMeta {
+				if c.Request.ContentLength >= 0 {
+					fields = append(fields, zap.Int64("content-length", c.Request.ContentLength))
+				}
+				if ct := c.Request.Header.Get("Content-Type"); ct != "" {
+					fields = append(fields, zap.String("content-type", ct))
+				}
+			}
+
+			if len(hashParts) > 0 {
+				fields = append(fields, zap.String("request-hash", requestHash(hashParts, c.Request.Method, path, query, requestBody)))
+			}
+
+			if boundary != "" && capture {
+				fields = append(fields, multipartFields(boundary, requestBody, requestTruncated)...)
+			}
+
+			if captureSkipped {
+				fields = append(fields, zap.String("body-capture-skipped", "backpressure"))
+			}
+
+			if requestReadErr != nil {
+				fields = append(fields, zap.String("request-body-read-error", requestReadErr.Error()))
+			}
+
+			if c.GetBool(bodyRestoreIssueKey) {
+				fields = append(fields, zap.Bool("body-restore-issue", true))
+			}
+
+			logBodies := !conf.BodyLoggingOptIn || c.GetBool(logBodyKey)
+			if logBodies && (conf.BodyOnError || len(bodyStatuses) > 0) {
+				status := c.Writer.Status()
+				logBodies = len(c.Errors) > 0 || bodyStatuses[status] || (conf.BodyOnError && status >= http.StatusBadRequest)
+			}
+
+			// Hijacked connections bypass the writer, there is no body to log,
+			// and ResponseBodyContentTypes may have left the body out.
+			if blw != nil && (blw.hijacked || blw.skipped) {
+				blw = nil
+			}
+			var responseBody []byte
+			var responseTruncated, responseDecoded bool
+			if blw != nil {
+				responseBody, responseTruncated = blw.body.Bytes(), blw.truncated
+				if enc := c.Writer.Header().Get("Content-Encoding"); enc != "" && (conf.LogResponseFieldCount || logBodies && logResponseBody) {
+					if body, truncated, ok := decodeBody(enc, responseBody, conf.MaxResponseBodySize); ok {
+						responseBody, responseDecoded = body, true
+						responseTruncated = responseTruncated || truncated
+					}
+				}
+			}
+			if blw != nil && conf.LogResponseFieldCount {
+				if n, ok := jsonFieldCount(responseBody); ok {
+					fields = append(fields, zap.Int("response-field-count", n))
+				}
+			}
+
+			// Multipart bodies are summarized by LogMultipartMetadata.
+			logRequestBody = logBodies && logRequestBody && boundary == ""
+			logResponseBody = logBodies && logResponseBody && blw != nil
+			if conf.TailSample && !troubled {
+				logRequestBody = logRequestBody && conf.LogRequestBody
+				logResponseBody = logResponseBody && conf.LogResponseBody
+			}
+			if logRequestBody || logResponseBody {
+				// bodies must not touch c: with a BodyBatcher it runs after the
+				// request ended.
+				contentLength, size := c.Request.ContentLength, int64(c.Writer.Size())
+				if responseDecoded {
+					// The decoded size is unknown past the limit.
+					size = -1
+				}
+				requestType, responseType := c.GetHeader("Content-Type"), c.Writer.Header().Get("Content-Type")
+				bodies := func() []zapcore.Field {
+					var out []zapcore.Field
+					if logRequestBody && len(requestBody) > 0 && len(requestBody) <= conf.HexDumpBodyMaxSize {
+						out = append(out, zap.String("request-body-hex", hex.EncodeToString(redactBody(conf, requestBody))))
+					} else if logRequestBody && conf.BodyPrefixBytes > 0 && len(requestBody) > conf.BodyPrefixBytes {
+						requestSize := contentLength
+						if requestSize < 0 && !requestTruncated {
+							requestSize = int64(len(requestBody))
+						}
+						if conf.LogRequestBodySize && !requestTruncated {
+							// Already logged on its own.
+							requestSize = -1
+						}
+						out = append(out, prefixBodyFields(conf, "request-body", requestBody, requestSize)...)
+					} else if logRequestBody && requestTruncated {
+						out = append(out, truncatedBodyFields(conf, "request-body", requestBody, contentLength)...)
+					} else if logRequestBody && requestReadErr == nil && requestBody != nil && len(requestBody) == 0 {
+						// Told apart from requests without a body, which log nothing.
+						out = append(out, zap.String("request-body", ""))
+					} else if logRequestBody && requestReadErr == nil {
+						out = append(out, bodyFields(conf, "request-body", requestType, requestBody)...)
+					}
+					if logResponseBody && conf.BodyPrefixBytes > 0 && len(responseBody) > conf.BodyPrefixBytes {
+						responseSize := size
+						if responseDecoded && !responseTruncated {
+							responseSize = int64(len(responseBody))
+						}
+						out = append(out, prefixBodyFields(conf, "response-body", responseBody, responseSize)...)
+					} else if logResponseBody && responseTruncated {
+						out = append(out, truncatedBodyFields(conf, "response-body", responseBody, size)...)
+					} else if logResponseBody {
+						out = append(out, bodyFields(conf, "response-body", responseType, responseBody)...)
+					}
+					return out
+				}
+				if conf.BodyBatcher != nil {
+					// The body buffers return to the pool before bodies runs.
+					if logRequestBody && len(requestBody) > 0 {
+						requestBody = append([]byte(nil), requestBody...)
+					}
+					if logResponseBody && !responseDecoded {
+						responseBody = append([]byte(nil), responseBody...)
+					}
+				}
+				if conf.BodyBatcher == nil {
+					fields = append(fields, bodies()...)
+				} else if ref, ok := conf.BodyBatcher.enqueue(names, bodies); ok {
+					fields = append(fields, zap.String("body-ref", ref))
+				} else {
+					fields = append(fields, zap.Bool("body-dropped", true))
+				}
+			}
+
+			if conf.IncludeRuntimeStatsOnError && len(c.Errors) > 0 {
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				fields = append(fields,
+					zap.Uint64("heap-alloc", m.HeapAlloc),
+					zap.Uint64("heap-inuse", m.HeapInuse),
+					zap.Uint32("num-gc", m.NumGC),
+					zap.Int("goroutines", runtime.NumGoroutine()),
+				)
+			}
+
+			if conf.IncludeGoroutineID && (len(c.Errors) > 0 || c.GetBool(panicKey)) {
+				if id, ok := goroutineID(); ok {
+					fields = append(fields, zap.Uint64("goroutine", id))
+				}
+			}
+
+			level := conf.DefaultLevel
+			if conf.LevelFunc != nil {
+				level = conf.LevelFunc(c)
+			}
+			if v, ok := c.Get(LevelKey); ok {
+				if l, ok := v.(zapcore.Level); ok {
+					level = l
+				}
+			}
+			if nonstandardMethod && level < zapcore.WarnLevel {
+				level = zapcore.WarnLevel
+			}
+			if conf.SlowThreshold > 0 && latency > conf.SlowThreshold {
+				fields = append(fields, zap.Bool("slow", true))
+				if level < zapcore.WarnLevel {
+					level = zapcore.WarnLevel
+				}
+			}
+			if c.GetBool(panicKey) {
+				level = zapcore.ErrorLevel
+				fields = append(fields, zap.Bool("panic", true))
+			}
+
+			// Only built-in fields are renamed, user fields pass through.
+			names.rename(fields[renameFrom:])
+
+			// The trace fields keep their OpenTelemetry names.
+			if conf.TraceContext {
+				fields = append(fields, OtelContext(c)...)
 			}
 
 			if conf.Context != nil {
 				fields = append(fields, conf.Context(c)...)
 			}
+			fields = append(fields, addedFields(c)...)
+
+			if conf.ErrorContext != nil && len(c.Errors) > 0 {
+				fields = append(fields, conf.ErrorContext(c)...)
+			}
+
+			if conf.LogMaxFieldSize {
+				fields = append(fields, zap.Int(names.key("max-field-size"), maxFieldSize(fields)))
+			}
+
+			if fieldRank != nil {
+				orderFields(fields, fieldRank)
+			}
 
-			if len(c.Errors) > 0 {
-				// Append error field if this is an erroneous request.
-				for _, e := range c.Errors.Errors() {
-					logger.Error(e, fields...)
+			errs := c.Errors.Errors()
+			// errLevels holds the level of each error, for ErrorLevelFunc.
+			var errLevels []zapcore.Level
+			if conf.ErrorLevelFunc != nil && len(c.Errors) > 0 {
+				errLevels = make([]zapcore.Level, len(c.Errors))
+				for i, e := range c.Errors {
+					errLevels[i] = conf.ErrorLevelFunc(e.Type, c.Writer.Status())
 				}
-			} else {
-				if zl, ok := logger.(*zap.Logger); ok {
-					zl.Log(conf.DefaultLevel, "", fields...)
-				} else if conf.DefaultLevel == zapcore.InfoLevel {
-					logger.Info(path, fields...)
+			}
+			// write may run after the request with DedupWindow or Async, so it
+			// must not read c.
+			var aggregated ginErrors
+			if conf.AggregateErrors {
+				aggregated = append(aggregated, c.Errors...)
+			}
+			logError := func(i int, msg string, fields []zapcore.Field) {
+				if conf.ErrorMessage != "" {
+					fields = append(fields[:len(fields):len(fields)], zap.String(names.key("error"), msg))
+					msg = conf.ErrorMessage
+				}
+				if errLevels == nil {
+					logger.Error(msg, fields...)
+					return
+				}
+				errLevel := errLevels[i]
+				if conf.AggregateErrors {
+					for _, l := range errLevels {
+						if l > errLevel {
+							errLevel = l
+						}
+					}
+				}
+				if ll, ok := logger.(LevelLogger); ok {
+					ll.Log(errLevel, msg, fields...)
+				} else if errLevel == zapcore.InfoLevel {
+					logger.Info(msg, fields...)
+				} else {
+					logger.Error(msg, fields...)
+				}
+			}
+			write := func(fields []zapcore.Field) {
+				if len(errs) > 0 && conf.AggregateErrors {
+					logError(0, errs[0], seal(append(fields[:len(fields):len(fields)], zap.Array(names.key("errors"), aggregated))))
+				} else if len(errs) > 0 {
+					// Append error field if this is an erroneous request.
+					for n, e := range errs {
+						if i := strings.IndexByte(e, '\n'); conf.FirstLineErrorsOnly && i >= 0 {
+							logError(n, e[:i], seal(append(fields[:len(fields):len(fields)], zap.String(names.key("error-detail"), e[i+1:]))))
+							continue
+						}
+						logError(n, e, seal(fields))
+					}
 				} else {
-					logger.Error(path, fields...)
+					if ll, ok := logger.(LevelLogger); ok {
+						ll.Log(level, successMessage, seal(fields)...)
+						return
+					}
+					// Other loggers only have Info and Error, and log the
+					// path unless a SuccessMessage is set.
+					msg := conf.SuccessMessage
+					if msg == "" {
+						msg = loggedPath
+					}
+					if level == zapcore.InfoLevel {
+						logger.Info(msg, seal(fields)...)
+					} else {
+						logger.Error(msg, seal(fields)...)
+					}
 				}
 			}
+
+			if conf.Async != nil {
+				writeNow := write
+				write = func(fields []zapcore.Field) {
+					conf.Async.enqueue(func() { writeNow(fields) })
+				}
+			}
+
+			if dedup != nil {
+				key := c.Request.Method + " " + path + " " + strconv.Itoa(c.Writer.Status()) + " " + resolveClientIP(c, conf)
+				dedup.log(key, fields, write)
+			} else {
+				write(fields)
+			}
+			if pooled != nil {
+				putFields(pooled, fields)
+			}
 		}
 	}
 }
 
-func defaultHandleRecovery(c *gin.Context, err interface{}) {
-	c.AbortWithStatus(http.StatusInternalServerError)
+// timeNow is the default Config.Clock, a variable so tests can replace it.
+var timeNow = time.Now
+
+func (conf *Config) clock() func() time.Time {
+	if conf.Clock != nil {
+		return conf.Clock
+	}
+	return timeNow
 }
 
-// RecoveryWithZap returns a gin.HandlerFunc (middleware)
-// that recovers from any panics and logs requests using uber-go/zap.
-// All errors are logged using zap.Error().
-// stack means whether output the stack info.
-// The stack info is easy to find where the error occurs but the stack info is too large.
-func RecoveryWithZap(logger ZapLogger, stack bool) gin.HandlerFunc {
-	return CustomRecoveryWithZap(logger, stack, defaultHandleRecovery)
+// rateLimitHeaders maps rate-limit response headers to their fields.
+var rateLimitHeaders = []struct{ key, header string }{
+	{"ratelimit-limit", "Limit"},
+	{"ratelimit-remaining", "Remaining"},
+	{"ratelimit-reset", "Reset"},
 }
 
-// CustomRecoveryWithZap returns a gin.HandlerFunc (middleware) with a custom recovery handler
-// that recovers from any panics and logs requests using uber-go/zap.
-// All errors are logged using zap.Error().
-// stack means whether output the stack info.
-// The stack info is easy to find where the error occurs but the stack info is too large.
-func CustomRecoveryWithZap(logger ZapLogger, stack bool, recovery gin.RecoveryFunc) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				// Check for a broken connection, as it is not really a
-				// condition that warrants a panic stack trace.
-				var brokenPipe bool
-				if ne, ok := err.(*net.OpError); ok {
-					if se, ok := ne.Err.(*os.SyscallError); ok {
-						if strings.Contains(strings.ToLower(se.Error()), "broken pipe") ||
-							strings.Contains(strings.ToLower(se.Error()), "connection reset by peer") {
-							brokenPipe = true
-						}
-					}
-				}
+func rateLimitFields(h http.Header) []zapcore.Field {
+	var fields []zapcore.Field
+	for _, rl := range rateLimitHeaders {
+		v := h.Get("X-RateLimit-" + rl.header)
+		if v == "" {
+			v = h.Get("RateLimit-" + rl.header)
+		}
+		if v != "" {
+			fields = append(fields, zap.String(rl.key, v))
+		}
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		fields = append(fields, zap.String("retry-after", v))
+	}
+	return fields
+}
 
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
-				if brokenPipe {
-					logger.Error(c.Request.URL.Path,
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-					// If the connection is dead, we can't write a status to it.
-					c.Error(err.(error)) //nolint: errcheck
-					c.Abort()
-					return
-				}
+// unitSymbol returns the symbol of a LatencyUnit, or "value" for units
+// without one.
+func unitSymbol(unit time.Duration) string {
+	switch unit {
+	case time.Nanosecond:
+		return "ns"
+	case time.Microsecond:
+		return "us"
+	case time.Millisecond:
+		return "ms"
+	case time.Second:
+		return "sec"
+	case time.Minute:
+		return "min"
+	case time.Hour:
+		return "h"
+	}
+	return "value"
+}
 
-				if stack {
-					logger.Error("[Recovery from panic]",
-						zap.Time("time", time.Now()),
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-						zap.String("stack", string(debug.Stack())),
-					)
-				} else {
-					logger.Error("[Recovery from panic]",
-						zap.Time("time", time.Now()),
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-				}
-				recovery(c, err)
-			}
-		}()
-		c.Next()
+// sortedBuckets returns a sorted copy of the LatencyBuckets bounds.
+func sortedBuckets(bounds []time.Duration) []time.Duration {
+	buckets := cloneSlice(bounds)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return buckets
+}
+
+// latencyBucket returns the label of the smallest of the sorted buckets that
+// is at least latency, or of the overflow bucket past the largest.
+func latencyBucket(latency time.Duration, buckets []time.Duration) string {
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i] >= latency })
+	if i == len(buckets) {
+		return "gt_" + buckets[len(buckets)-1].String()
+	}
+	return "le_" + buckets[i].String()
+}
+
+// shouldSkipByRegexp reports whether any of regexps matches path. Patterns
+// are not implicitly anchored, so `/health` also matches "/api/health".
+func shouldSkipByRegexp(path string, regexps []*regexp.Regexp) bool {
+	for _, reg := range regexps {
+		if reg.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyResponse reports whether c is a 2xx response without a body that
+// should have had one.
+func isEmptyResponse(c *gin.Context) bool {
+	switch status := c.Writer.Status(); {
+	case status < 200 || status > 299, status == http.StatusNoContent, status == http.StatusResetContent:
+		return false
+	}
+	return c.Request.Method != http.MethodHead && c.Writer.Size() <= 0
+}
+
+func isStandardMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// maxFieldSize returns the length in bytes of the longest string field.
+func maxFieldSize(fields []zapcore.Field) int {
+	var max int
+	for _, f := range fields {
+		if f.Type == zapcore.StringType && len(f.String) > max {
+			max = len(f.String)
+		}
+	}
+	return max
+}
+
+// cacheHeaders maps cache-related response headers to their fields.
+var cacheHeaders = []struct{ key, header string }{
+	{"etag", "ETag"},
+	{"cache-control", "Cache-Control"},
+	{"age", "Age"},
+}
+
+func cacheFields(h http.Header) []zapcore.Field {
+	var fields []zapcore.Field
+	for _, ch := range cacheHeaders {
+		if v := h.Get(ch.header); v != "" {
+			fields = append(fields, zap.String(ch.key, v))
+		}
+	}
+	return fields
+}
+
+// parseUpstreamTiming parses a Go duration or a number of milliseconds.
+func parseUpstreamTiming(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	d, err := time.ParseDuration(v)
+	return d, err == nil
+}
+
+// statusClass returns the "Nxx" class of status.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// requestURI returns the request target uri with its path rewritten by
+// maskPath, when not nil, and its query redacted with redactRawQuery.
+func requestURI(uri string, maskPath func(string) string, redact []string) string {
+	target, query, hasQuery := strings.Cut(uri, "?")
+	if maskPath != nil {
+		if strings.HasPrefix(target, "/") {
+			target = maskPath(target)
+		} else if u, err := url.Parse(target); err == nil && u.Host != "" {
+			// The absolute URI of a proxy request.
+			target = u.Scheme + "://" + u.Host + maskPath(u.EscapedPath())
+		}
+	}
+	if !hasQuery {
+		return target
+	}
+	return target + "?" + redactRawQuery(query, redact)
+}
+
+// queryField returns the "query" field, parsed into an object when
+// conf.StructuredQuery is set, with the values of the parameters named in
+// conf.RedactBodyKeys redacted, and whether conf.SanitizeUTF8 escaped any of
+// it.
+func queryField(conf *Config, query string) (zapcore.Field, bool) {
+	if !conf.StructuredQuery {
+		if conf.DecodeQuery {
+			query = decodeQuery(query, conf.RedactBodyKeys)
+		} else {
+			query = redactRawQuery(query, conf.RedactBodyKeys)
+		}
+		var sanitized bool
+		if conf.SanitizeUTF8 {
+			query, sanitized = sanitizeUTF8(query)
+		}
+		return zap.String("query", query), sanitized
+	}
+	values, _ := url.ParseQuery(query)
+	return zap.Object("query", queryObject{values: values, redact: conf.RedactBodyKeys}), false
+}
+
+// requestHash returns the hex SHA-256 of the selected request parts.
+func requestHash(parts map[string]bool, method, path, query string, body []byte) string {
+	h := sha256.New()
+	for _, part := range []struct {
+		name  string
+		value []byte
+	}{
+		{"method", []byte(method)},
+		{"path", []byte(path)},
+		{"query", []byte(query)},
+		{"body", body},
+	} {
+		if parts[part.name] {
+			h.Write(part.value)
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ginStaticHandlerPrefix prefixes the names of the handlers gin registers
+// for its static routes.
+const ginStaticHandlerPrefix = "github.com/gin-gonic/gin.(*RouterGroup)."
+
+// isStaticHandler reports whether name is one of gin's static file handlers.
+func isStaticHandler(name string) bool {
+	if !strings.HasPrefix(name, ginStaticHandlerPrefix) {
+		return false
+	}
+	name = name[len(ginStaticHandlerPrefix):]
+	return strings.HasPrefix(name, "createStaticHandler.") || strings.HasPrefix(name, "StaticFile")
+}
+
+// numberField returns an Int64 or Float64 field for numeric values.
+func numberField(key string, v interface{}) (zapcore.Field, bool) {
+	switch n := v.(type) {
+	case int:
+		return zap.Int64(key, int64(n)), true
+	case int32:
+		return zap.Int64(key, int64(n)), true
+	case int64:
+		return zap.Int64(key, n), true
+	case uint:
+		return zap.Uint64(key, uint64(n)), true
+	case uint32:
+		return zap.Uint64(key, uint64(n)), true
+	case uint64:
+		return zap.Uint64(key, n), true
+	case float32:
+		return zap.Float64(key, float64(n)), true
+	case float64:
+		return zap.Float64(key, n), true
+	}
+	return zapcore.Field{}, false
+}
+
+// contextValue looks key up in the gin context first and then in the
+// request context, where server-level hooks usually store their values.
+func contextValue(c *gin.Context, key string) (interface{}, bool) {
+	if v, ok := c.Get(key); ok {
+		return v, true
 	}
+	v := c.Request.Context().Value(key)
+	return v, v != nil
 }
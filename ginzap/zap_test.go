@@ -1,11 +1,19 @@
 package ginzap
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -180,6 +188,430 @@ func TestLoggerSkipper(t *testing.T) {
 	}
 }
 
+// plainLogger is a ZapLogger that is not a *zap.Logger.
+type plainLogger struct {
+	l *zap.Logger
+}
+
+func (p plainLogger) Info(msg string, fields ...zap.Field)  { p.l.Info(msg, fields...) }
+func (p plainLogger) Error(msg string, fields ...zap.Field) { p.l.Error(msg, fields...) }
+
+func TestSuccessMessage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	zl, observed := buildDummyLogger()
+	for _, tt := range []struct {
+		logger ZapLogger
+		conf   *Config
+		want   string
+	}{
+		{zl, &Config{}, ""},
+		{zl, &Config{SuccessMessage: "request"}, "request"},
+		{plainLogger{zl}, &Config{}, testPath},
+		{plainLogger{zl}, &Config{SuccessMessage: "request"}, "request"},
+	} {
+		r := gin.New()
+		r.Use(GinzapWithConfig(tt.logger, tt.conf))
+		r.GET(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		r.ServeHTTP(res, req)
+
+		entries := observed.All()
+		if msg := entries[len(entries)-1].Message; msg != tt.want {
+			t.Fatalf("the message should be %q but was %q", tt.want, msg)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{ErrorMessage: "request failed", LogErrorBool: true}))
+	r.GET(testPath, func(c *gin.Context) {
+		_ = c.Error(errors.New("db timeout"))
+		_ = c.Error(errors.New("cache miss"))
+		c.Status(500)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+	entries := observed.All()
+	if len(entries) != 2 {
+		t.Fatalf("one line per error should be logged, got %d", len(entries))
+	}
+	for i, want := range []string{"db timeout", "cache miss"} {
+		if entries[i].Message != "request failed" {
+			t.Fatalf("the message should be ErrorMessage but was %q", entries[i].Message)
+		}
+		var errorFields []zapcore.Field
+		for _, f := range entries[i].Context {
+			if f.Key == "error" {
+				errorFields = append(errorFields, f)
+			}
+		}
+		if len(errorFields) != 1 || errorFields[0].String != want {
+			t.Fatalf("line %d should have one error field %q, got %v", i, want, errorFields)
+		}
+	}
+}
+
+func TestLoggerFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	audit, auditObserved := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LoggerFunc: func(c *gin.Context) ZapLogger {
+			if strings.HasPrefix(c.FullPath(), "/admin") {
+				return audit
+			}
+			return nil
+		},
+	}))
+
+	r.GET("/admin/users", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{"/admin/users", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if len(auditObserved.All()) != 1 || auditObserved.All()[0].ContextMap()["path"] != "/admin/users" {
+		t.Fatalf("the admin request should go to the audit logger, got %v", auditObserved.All())
+	}
+	if len(observed.All()) != 1 || observed.All()[0].ContextMap()["path"] != testPath {
+		t.Fatalf("other requests should go to the default logger, got %v", observed.All())
+	}
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	buckets := []time.Duration{time.Second, 10 * time.Millisecond, 100 * time.Millisecond}
+	tests := []struct {
+		latency time.Duration
+		want    string
+	}{
+		{0, "le_10ms"},
+		{10 * time.Millisecond, "le_10ms"},
+		{10*time.Millisecond + 1, "le_100ms"},
+		{time.Second, "le_1s"},
+		{time.Second + 1, "gt_1s"},
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		r := gin.New()
+		ticks := []time.Time{start, start.Add(tt.latency)}
+		logger, observed := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, &Config{
+			LatencyBuckets: buckets,
+			Clock: func() time.Time {
+				t := ticks[0]
+				ticks = ticks[1:]
+				return t
+			},
+		}))
+		r.GET(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+
+		if got := observed.All()[0].ContextMap()["latency-bucket"]; got != tt.want {
+			t.Fatalf("latency-bucket of %v should be %q but was %v", tt.latency, tt.want, got)
+		}
+	}
+
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{}))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", testPath, nil))
+	if _, ok := observed.All()[0].ContextMap()["latency-bucket"]; ok {
+		t.Fatal("latency-bucket should not be logged without LatencyBuckets")
+	}
+}
+
+func TestLatencyUnit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		conf *Config
+		key  string
+		want interface{}
+	}{
+		{&Config{LatencyUnit: time.Millisecond}, "latency-ms", int64(1500)},
+		{&Config{LatencyUnit: time.Second, LatencyAsFloat: true}, "latency-sec", 1.5},
+		{&Config{LatencyUnit: time.Millisecond, LatencyKey: "duration_ms", OmitLatencyDuration: true}, "duration_ms", int64(1500)},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		ticks := []time.Time{start, start.Add(1500 * time.Millisecond)}
+		tt.conf.Clock = func() time.Time {
+			t := ticks[0]
+			ticks = ticks[1:]
+			return t
+		}
+		logger, observed := buildDummyLogger()
+		r.Use(GinzapWithConfig(logger, tt.conf))
+		r.GET(testPath, func(c *gin.Context) {
+			c.Status(204)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		r.ServeHTTP(res, req)
+
+		fields := observed.All()[0].ContextMap()
+		if fields[tt.key] != tt.want {
+			t.Fatalf("%s should be %v but was %v", tt.key, tt.want, fields[tt.key])
+		}
+		if _, ok := fields["latency"]; ok == tt.conf.OmitLatencyDuration {
+			t.Fatalf("latency presence should be %v", !tt.conf.OmitLatencyDuration)
+		}
+	}
+}
+
+func TestServiceNameAndHostname(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{ServiceName: "billing", IncludeHostname: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["service"] != "billing" || fields["host"] != host {
+		t.Fatalf("service and host should be logged, got %v and %v", fields["service"], fields["host"])
+	}
+}
+
+func TestClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ticks := []time.Time{start, start.Add(1500 * time.Millisecond)}
+	clock := func() time.Time {
+		t := ticks[0]
+		ticks = ticks[1:]
+		return t
+	}
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{TimeFormat: time.RFC3339, Clock: clock}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["latency"] != 1500*time.Millisecond || fields["time"] != "2024-05-01T12:00:01Z" {
+		t.Fatalf("latency and time should come from the clock, got %v and %v", fields["latency"], fields["time"])
+	}
+}
+
+func TestSkipMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		SkipMethods: []string{"head", "OPTIONS"},
+		SkipPaths:   []string{"/skipped"},
+	}))
+
+	r.Any(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/skipped", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, tt := range []struct{ method, path string }{
+		{"HEAD", testPath},
+		{"OPTIONS", testPath},
+		{"GET", "/skipped"},
+		{"GET", testPath},
+	} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, tt.method, tt.path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if len(observed.All()) != 1 {
+		t.Fatalf("Log should be 1 line but there're %d", len(observed.All()))
+	}
+	if method := observed.All()[0].ContextMap()["method"]; method != "GET" {
+		t.Fatalf("only the GET request should be logged, got %v", method)
+	}
+}
+
+func TestSkipPathsBeforeCapture(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		SkipPaths:       []string{"/skipped"},
+		LogRequestBody:  true,
+		LogResponseBody: true,
+	}))
+
+	body := io.NopCloser(strings.NewReader(`{"a":1}`))
+	r.POST("/skipped", func(c *gin.Context) {
+		if c.Request.Body != body {
+			t.Error("the body of a skipped path should not be read")
+		}
+		if _, ok := c.Writer.(*bodyLogWriter); ok {
+			t.Error("the writer of a skipped path should not be wrapped")
+		}
+		c.Status(204)
+	})
+
+	req := httptest.NewRequest("POST", "/skipped", nil)
+	req.Body, req.ContentLength = body, 7
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if observed.Len() != 0 {
+		t.Fatalf("a skipped path should not be logged, got %d lines", observed.Len())
+	}
+}
+
+func TestFlagEmptyResponses(t *testing.T) {
+	r := gin.New()
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{FlagEmptyResponses: true}))
+	r.Any("/empty", func(c *gin.Context) {
+		c.Status(200)
+	})
+	r.GET("/body", func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+	r.GET("/no-content", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		c.Status(404)
+	})
+
+	for _, tt := range []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/empty", true},
+		{"HEAD", "/empty", false},
+		{"GET", "/body", false},
+		{"GET", "/no-content", false},
+		{"GET", "/missing", false},
+	} {
+		observed.TakeAll()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if _, ok := observed.All()[0].ContextMap()["empty-response"]; ok != tt.want {
+			t.Fatalf("empty-response of %s %s should be %v", tt.method, tt.path, tt.want)
+		}
+	}
+}
+
+func TestSkipStatusCodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		SkipStatusCodes: []int{200},
+		StatusSkipper:   func(status int) bool { return status < 400 },
+	}))
+
+	for _, status := range []int{200, 304, 404} {
+		status := status
+		r.GET(fmt.Sprintf("/%d", status), func(c *gin.Context) {
+			c.Status(status)
+		})
+	}
+	r.GET("/failed", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(200)
+	})
+
+	for _, path := range []string{"/200", "/304", "/404", "/failed"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if len(observed.All()) != 2 {
+		t.Fatalf("Log should be 2 lines but there're %d", len(observed.All()))
+	}
+	for i, want := range []string{"/404", "/failed"} {
+		if path := observed.All()[i].ContextMap()["path"]; path != want {
+			t.Fatalf("line %d should log %s but logged %v", i, want, path)
+		}
+	}
+}
+
+func TestShouldSkipByRegexp(t *testing.T) {
+	health := regexp.MustCompile(`/health`)
+	anchored := regexp.MustCompile(`^/health$`)
+	metrics := regexp.MustCompile(`^/metrics`)
+
+	tests := []struct {
+		name    string
+		path    string
+		regexps []*regexp.Regexp
+		want    bool
+	}{
+		{"no regexps", "/health", nil, false},
+		{"one matching", "/health", []*regexp.Regexp{health}, true},
+		{"one non-matching", "/users", []*regexp.Regexp{health}, false},
+		{"multiple with one match", "/metrics/go", []*regexp.Regexp{health, metrics}, true},
+		{"multiple without match", "/users", []*regexp.Regexp{health, metrics}, false},
+		{"unanchored matches a substring", "/api/health", []*regexp.Regexp{health}, true},
+		{"anchored needs the whole path", "/api/health", []*regexp.Regexp{anchored}, false},
+		{"anchored exact path", "/health", []*regexp.Regexp{anchored}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldSkipByRegexp(tt.path, tt.regexps); got != tt.want {
+				t.Fatalf("shouldSkipByRegexp(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSkipPathRegexps(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -224,3 +656,1512 @@ func TestSkipPathRegexps(t *testing.T) {
 		t.Fatalf("logged path should be /test but %s", pathStr)
 	}
 }
+
+func TestQueueWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	queued := time.Now().Add(-time.Second)
+	r.Use(func(c *gin.Context) {
+		if c.Request.URL.Path == testPath {
+			c.Set("queue-start", queued)
+		} else {
+			c.Set("queue-start", "not a time")
+		}
+	})
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{QueueStartContextKey: "queue-start"}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/wrong_type", func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, path := range []string{testPath, "/wrong_type"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if len(observed.All()) != 2 {
+		t.Fatalf("Log should be 2 lines but there're %d", len(observed.All()))
+	}
+
+	wait, ok := observed.All()[0].ContextMap()["queue-wait"].(time.Duration)
+	if !ok || wait < time.Second {
+		t.Fatalf("queue-wait should be at least 1s but was %v", wait)
+	}
+
+	if _, ok := observed.All()[1].ContextMap()["queue-wait"]; ok {
+		t.Fatal("queue-wait should be omitted when the value is not a time.Time")
+	}
+}
+
+func TestStreamID(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{StreamIDContextKey: "stream-id"}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	ctx := context.WithValue(context.Background(), "stream-id", uint32(7)) //nolint:staticcheck
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if id := observed.All()[0].ContextMap()["stream-id"]; id != uint32(7) {
+		t.Fatalf("stream-id should be 7 but was %v", id)
+	}
+	if _, ok := observed.All()[1].ContextMap()["stream-id"]; ok {
+		t.Fatal("stream-id should be omitted when unavailable")
+	}
+}
+
+func TestPrincipalType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			c.Set("principal-type", "service")
+		}
+	})
+	r.Use(GinzapWithConfig(logger, &Config{PrincipalTypeContextKey: "principal-type"}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, auth := range []string{"Bearer t", ""} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		r.ServeHTTP(res, req)
+	}
+
+	if pt := observed.All()[0].ContextMap()["principal-type"]; pt != "service" {
+		t.Fatalf("principal-type should be service but was %v", pt)
+	}
+	if _, ok := observed.All()[1].ContextMap()["principal-type"]; ok {
+		t.Fatal("principal-type should be omitted when absent")
+	}
+}
+
+func TestDualTimestamp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{UTC: true, DualTimestamp: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+	after := time.Now().UnixNano() / int64(time.Millisecond)
+
+	fields := observed.All()[0].ContextMap()
+	if err := timestampLocationCheck(fields["time"].(string), time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	ts, ok := fields["ts"].(int64)
+	if !ok || ts < before || ts > after {
+		t.Fatalf("ts should be between %d and %d but was %v", before, after, fields["ts"])
+	}
+}
+
+func TestLogTransferEncoding(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogTransferEncoding: true}))
+
+	r.GET("/small", func(c *gin.Context) {
+		c.JSON(200, gin.H{"a": 1})
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		_, _ = c.Writer.Write([]byte("chunk"))
+		c.Writer.Flush()
+	})
+	r.GET("/large", func(c *gin.Context) {
+		c.String(200, strings.Repeat("x", 4096))
+	})
+	r.GET("/sized", func(c *gin.Context) {
+		c.Header("Content-Length", "2")
+		c.String(200, "ok")
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for path, want := range map[string]bool{"/small": false, "/stream": true, "/large": true, "/sized": false} {
+		observed.TakeAll()
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		wire := len(res.TransferEncoding) > 0 && res.TransferEncoding[0] == "chunked"
+		chunked, logged := observed.All()[0].ContextMap()["chunked"]
+		if wire != want {
+			t.Fatalf("%s: chunked on the wire should be %v", path, want)
+		}
+		if logged != want || (logged && chunked != true) {
+			t.Fatalf("%s: chunked on the wire is %v but the log had %v", path, wire, chunked)
+		}
+	}
+}
+
+func TestFlagNonstandardMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{FlagNonstandardMethods: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, method := range []string{"GET", "get", "PURGE"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, method, testPath, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if entry := observed.All()[0]; entry.Level != zapcore.InfoLevel || entry.ContextMap()["method-nonstandard"] != nil {
+		t.Fatalf("standard methods should not be flagged: %v %v", entry.Level, entry.ContextMap())
+	}
+	for _, entry := range observed.All()[1:] {
+		fields := entry.ContextMap()
+		if entry.Level != zapcore.WarnLevel || fields["method-nonstandard"] != true {
+			t.Fatalf("%v should be flagged at Warn: %v %v", fields["method"], entry.Level, fields)
+		}
+	}
+	if method := observed.All()[1].ContextMap()["method"]; method != "get" {
+		t.Fatalf("the raw method should be kept but was %v", method)
+	}
+}
+
+func TestLogResponseSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogResponseSize: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.String(200, "hello")
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{testPath, "/empty"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if size := observed.All()[0].ContextMap()["response-size"]; size != int64(5) {
+		t.Fatalf("response-size should be 5 but was %v", size)
+	}
+	if size := observed.All()[1].ContextMap()["response-size"]; size != int64(0) {
+		t.Fatalf("response-size should be clamped to 0 but was %v", size)
+	}
+}
+
+func TestLogMaxFieldSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogMaxFieldSize: true, LogResponseBody: true}))
+
+	body := `{"data":"` + strings.Repeat("x", 100) + `"}`
+	r.GET(testPath, func(c *gin.Context) {
+		c.Data(200, "application/json", []byte(body))
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if size := observed.All()[0].ContextMap()["max-field-size"]; size != int64(len(body)) {
+		t.Fatalf("max-field-size should be the body length %d but was %v", len(body), size)
+	}
+}
+
+func TestLogOnlyWhen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogOnlyWhen: func(c *gin.Context) bool {
+			return c.GetBool("debug")
+		},
+	}))
+
+	r.GET("/flagged", func(c *gin.Context) {
+		c.Set("debug", true)
+		c.Status(204)
+	})
+	r.GET("/plain", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/broken", func(c *gin.Context) {
+		c.Status(502)
+	})
+	r.GET("/error", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(400)
+	})
+
+	for _, path := range []string{"/flagged", "/plain", "/broken", "/error"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	var paths []string
+	for _, entry := range observed.All() {
+		paths = append(paths, entry.ContextMap()["path"].(string))
+	}
+	if strings.Join(paths, ",") != "/flagged,/broken,/error" {
+		t.Fatalf("only flagged and failed requests should be logged, got %v", paths)
+	}
+}
+
+func TestLevelFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LevelFunc: DefaultLevelFunc}))
+
+	for _, status := range []int{200, 302, 404, 503} {
+		status := status
+		r.GET(fmt.Sprintf("/%d", status), func(c *gin.Context) {
+			c.Status(status)
+		})
+	}
+
+	for _, status := range []int{200, 302, 404, 503} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("/%d", status), nil)
+		r.ServeHTTP(res, req)
+	}
+
+	want := []zapcore.Level{zapcore.InfoLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel}
+	for i, entry := range observed.All() {
+		if entry.Level != want[i] {
+			t.Fatalf("line %d should be logged at %v but was %v", i, want[i], entry.Level)
+		}
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LevelFunc: DefaultLevelFunc}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		SetLevel(c, zapcore.WarnLevel)
+		c.Status(503)
+	})
+	r.GET("/error", func(c *gin.Context) {
+		SetLevel(c, zapcore.InfoLevel)
+		_ = c.Error(errors.New("failed"))
+	})
+
+	for _, path := range []string{testPath, "/error"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if level := observed.All()[0].Level; level != zapcore.WarnLevel {
+		t.Fatalf("SetLevel should override LevelFunc but the level was %v", level)
+	}
+	if level := observed.All()[1].Level; level != zapcore.ErrorLevel {
+		t.Fatalf("errors should still be logged at Error but the level was %v", level)
+	}
+}
+
+func TestSlowThreshold(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LevelFunc: DefaultLevelFunc, SlowThreshold: 20 * time.Millisecond}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(204)
+	})
+	r.GET("/slow-failure", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(503)
+	})
+
+	for _, path := range []string{testPath, "/slow", "/slow-failure"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fast := observed.All()[0]
+	if _, ok := fast.ContextMap()["slow"]; ok || fast.Level != zapcore.InfoLevel {
+		t.Fatalf("fast requests should not be flagged, got %v %v", fast.Level, fast.ContextMap())
+	}
+	for i, want := range []zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel} {
+		entry := observed.All()[i+1]
+		if entry.ContextMap()["slow"] != true || entry.Level != want {
+			t.Fatalf("line %d should be slow at %v, got %v %v", i+1, want, entry.Level, entry.ContextMap())
+		}
+	}
+}
+
+func TestLogErrorBool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogErrorBool: true, ErrorStatusThreshold: 429}))
+
+	r.GET("/ok", func(c *gin.Context) {
+		c.Status(404)
+	})
+	r.GET("/limited", func(c *gin.Context) {
+		c.Status(429)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(200)
+	})
+
+	for _, path := range []string{"/ok", "/limited", "/fail"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	for i, want := range []bool{false, true, true} {
+		if got := observed.All()[i].ContextMap()["error"]; got != want {
+			t.Fatalf("line %d should have error=%v but had %v", i, want, got)
+		}
+	}
+}
+
+func TestLogAborted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogAborted: true}))
+	r.Use(func(c *gin.Context) {
+		if c.Query("token") == "" {
+			c.AbortWithStatus(401)
+		}
+	})
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(200)
+	})
+
+	for _, path := range []string{testPath + "?token=x", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	for i, want := range []bool{false, true} {
+		if got := observed.All()[i].ContextMap()["aborted"]; got != want {
+			t.Fatalf("line %d should have aborted=%v but had %v", i, want, got)
+		}
+	}
+}
+
+func TestContextError(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{}))
+
+	r.GET("/timeout", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Nanosecond)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		<-ctx.Done()
+		c.Status(503)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(200)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, path := range []string{"/timeout", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if got := observed.All()[0].ContextMap()["context-error"]; got != context.DeadlineExceeded.Error() {
+		t.Fatalf("context-error should be %q but was %v", context.DeadlineExceeded, got)
+	}
+	if _, ok := observed.All()[1].ContextMap()["context-error"]; ok {
+		t.Fatal("context-error should be omitted while the context is alive")
+	}
+}
+
+func namedHandler(c *gin.Context) {
+	c.Status(204)
+}
+
+func TestLogHandlerName(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogHandlerName: true}))
+	r.GET(testPath, namedHandler)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	want := "github.com/kilingzhang/go-dev-contrib/ginzap.namedHandler"
+	if got := observed.All()[0].ContextMap()["handler"]; got != want {
+		t.Fatalf("handler should be %q but was %v", want, got)
+	}
+}
+
+func TestLogProtocol(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogProtocol: true, LogRequestURI: true}))
+	r.Use(func(c *gin.Context) {
+		c.Request.URL.Path = "/rewritten"
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com"+testPath+"?a=1", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["proto"] != "HTTP/2.0" {
+		t.Fatalf("proto should be HTTP/2.0 but was %v", fields["proto"])
+	}
+	if want := "http://example.com" + testPath + "?a=1"; fields["request-uri"] != want {
+		t.Fatalf("request-uri should be %q but was %v", want, fields["request-uri"])
+	}
+}
+
+func TestLogRequestURIMasked(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogRequestURI:    true,
+		MaskPathSegments: []int{1},
+		RedactBodyKeys:   []string{"token"},
+	}))
+	r.GET("/reset/:token/confirm", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for target, want := range map[string]string{
+		"/reset/tok123/confirm?Token=abc&x=1":                   "/reset/*/confirm?Token=***&x=1",
+		"/reset/tok123/confirm":                                 "/reset/*/confirm",
+		"http://example.com/reset/tok123/confirm?token=abc&x=1": "http://example.com/reset/*/confirm?token=***&x=1",
+	} {
+		observed.TakeAll()
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		r.ServeHTTP(res, req)
+
+		if got := observed.All()[0].ContextMap()["request-uri"]; got != want {
+			t.Fatalf("request-uri of %s should be %q but was %v", target, want, got)
+		}
+	}
+}
+
+func TestLogRateLimitHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRateLimitHeaders: true}))
+
+	r.GET("/limited", func(c *gin.Context) {
+		c.Header("X-RateLimit-Limit", "100")
+		c.Header("X-RateLimit-Remaining", "0")
+		c.Header("RateLimit-Reset", "30")
+		c.Header("Retry-After", "30")
+		c.Status(429)
+	})
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, path := range []string{"/limited", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	want := map[string]string{
+		"ratelimit-limit":     "100",
+		"ratelimit-remaining": "0",
+		"ratelimit-reset":     "30",
+		"retry-after":         "30",
+	}
+	for key, v := range want {
+		if fields[key] != v {
+			t.Errorf("%s should be %q but was %v", key, v, fields[key])
+		}
+	}
+	for key := range want {
+		if _, ok := observed.All()[1].ContextMap()[key]; ok {
+			t.Errorf("%s should be omitted when the header is absent", key)
+		}
+	}
+}
+
+func TestLogOrigin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogOrigin: true}))
+
+	r.OPTIONS(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	for _, origin := range []string{"https://app.example.com", ""} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "OPTIONS", testPath, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		r.ServeHTTP(res, req)
+	}
+
+	if origin := observed.All()[0].ContextMap()["origin"]; origin != "https://app.example.com" {
+		t.Fatalf("origin should be logged but was %v", origin)
+	}
+	if _, ok := observed.All()[1].ContextMap()["origin"]; ok {
+		t.Fatal("origin should be omitted when absent")
+	}
+}
+
+func TestLogCacheHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogCacheHeaders: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.Header("Cache-Control", "max-age=60")
+		c.String(200, "ok")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["etag"] != `"v1"` || fields["cache-control"] != "max-age=60" {
+		t.Fatalf("cache headers should be logged: %v", fields)
+	}
+	if _, ok := fields["age"]; ok {
+		t.Fatal("age should be omitted when absent")
+	}
+}
+
+func TestLogContentDisposition(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogContentDisposition: true}))
+
+	r.PUT(testPath, func(c *gin.Context) {
+		c.Status(201)
+	})
+
+	for _, cd := range []string{`attachment; filename="report.pdf"`, ""} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "PUT", testPath, strings.NewReader("data"))
+		if cd != "" {
+			req.Header.Set("Content-Disposition", cd)
+		}
+		r.ServeHTTP(res, req)
+	}
+
+	if cd := observed.All()[0].ContextMap()["content-disposition"]; cd != `attachment; filename="report.pdf"` {
+		t.Fatalf("content-disposition should be logged but was %v", cd)
+	}
+	if _, ok := observed.All()[1].ContextMap()["content-disposition"]; ok {
+		t.Fatal("content-disposition should be omitted when absent")
+	}
+}
+
+func TestLogRequestMeta(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogRequestMeta: true}))
+
+	r.PUT(testPath, func(c *gin.Context) {
+		c.Status(201)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "PUT", testPath, strings.NewReader("data"))
+	req.Header.Set("Content-Type", "text/plain")
+	r.ServeHTTP(res, req)
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "PUT", testPath, strings.NewReader("data"))
+	req.ContentLength = -1
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if fields["content-length"] != int64(4) || fields["content-type"] != "text/plain" {
+		t.Fatalf("content-length and content-type should be logged but were %v and %v", fields["content-length"], fields["content-type"])
+	}
+	fields = observed.All()[1].ContextMap()
+	if _, ok := fields["content-length"]; ok {
+		t.Fatalf("an unknown content-length should be omitted but was %v", fields["content-length"])
+	}
+	if _, ok := fields["content-type"]; ok {
+		t.Fatalf("an absent content-type should be omitted but was %v", fields["content-type"])
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{RequestHashFields: []string{"method", "path", "body"}}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(200, string(body))
+	})
+
+	for _, target := range []string{testPath + "?a=1", testPath + "?a=2"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "POST", target, strings.NewReader("payload"))
+		r.ServeHTTP(res, req)
+		if res.Body.String() != "payload" {
+			t.Fatalf("handler should read the body but got %q", res.Body.String())
+		}
+	}
+
+	sum := sha256.Sum256([]byte("POST\x00" + testPath + "\x00payload\x00"))
+	want := hex.EncodeToString(sum[:])
+	for _, line := range observed.All() {
+		if got := line.ContextMap()["request-hash"]; got != want {
+			t.Fatalf("request-hash should be %s but was %v", want, got)
+		}
+	}
+}
+
+func TestFirstLineErrorsOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{FirstLineErrorsOnly: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		_ = c.Error(errors.New("query failed\nat db.go:12\nat user.go:40"))
+		_ = c.Error(errors.New("single line"))
+		c.Status(500)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if len(observed.All()) != 2 {
+		t.Fatalf("Log should be 2 lines but there're %d", len(observed.All()))
+	}
+
+	first := observed.All()[0]
+	if first.Message != "query failed" {
+		t.Fatalf("message should be the first line but was %q", first.Message)
+	}
+	if detail := first.ContextMap()["error-detail"]; detail != "at db.go:12\nat user.go:40" {
+		t.Fatalf("error-detail should hold the remaining lines but was %q", detail)
+	}
+
+	second := observed.All()[1]
+	if _, ok := second.ContextMap()["error-detail"]; ok || second.Message != "single line" {
+		t.Fatalf("single-line errors should be logged unchanged, got %q %v", second.Message, second.ContextMap())
+	}
+}
+
+func TestApacheLatencyFields(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogLatencyMicros: true, LogLatencySeconds: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		time.Sleep(2 * time.Millisecond)
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	latency := fields["latency"].(time.Duration)
+	if us := fields["latency-us"]; us != int64(latency/time.Microsecond) || us.(int64) < 2000 {
+		t.Fatalf("latency-us should match latency %v but was %v", latency, us)
+	}
+	if sec := fields["latency-sec"]; sec != int64(0) {
+		t.Fatalf("latency-sec should round to 0 but was %v", sec)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{0, "unknown"},
+		{99, "unknown"},
+		{100, "1xx"},
+		{204, "2xx"},
+		{302, "3xx"},
+		{404, "4xx"},
+		{599, "5xx"},
+		{600, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := statusClass(tt.status); got != tt.want {
+			t.Errorf("statusClass(%d) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogStatusClass: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(404, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if class := observed.All()[0].ContextMap()["status-class"]; class != "4xx" {
+		t.Fatalf("status-class should be 4xx but was %v", class)
+	}
+}
+
+func TestLogStatusText(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogStatusText: true}))
+
+	r.GET("/missing", func(c *gin.Context) {
+		c.Status(404)
+	})
+	r.GET("/custom", func(c *gin.Context) {
+		c.Status(299)
+	})
+
+	for _, path := range []string{"/missing", "/custom"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if text := observed.All()[0].ContextMap()["status-text"]; text != "Not Found" {
+		t.Fatalf("status-text should be Not Found but was %v", text)
+	}
+	if text, ok := observed.All()[1].ContextMap()["status-text"]; ok {
+		t.Fatalf("status-text should be omitted for non-standard codes but was %v", text)
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		Context: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("tenant", "acme")}
+		},
+		ErrorContext: func(c *gin.Context) []zapcore.Field {
+			return []zapcore.Field{zap.String("error-category", "upstream")}
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("upstream timeout"))
+		c.Status(502)
+	})
+
+	for _, path := range []string{testPath, "/fail"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if _, ok := observed.All()[0].ContextMap()["error-category"]; ok {
+		t.Fatal("error-category should only be added on the error path")
+	}
+
+	errLine := observed.All()[1].Context
+	if n := len(errLine); errLine[n-2].Key != "tenant" || errLine[n-1].Key != "error-category" {
+		t.Fatalf("error fields should be appended after the context fields, got %v", errLine)
+	}
+}
+
+func TestLogQueryParamCount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogQueryParamCount: true, LogQueryParamNames: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, target := range []string{testPath + "?token=secret&b=1&b=2", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", target, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	if fields["query-param-count"] != int64(2) {
+		t.Fatalf("query-param-count should be 2 but was %v", fields["query-param-count"])
+	}
+	names := fields["query-param-names"].([]interface{})
+	if len(names) != 2 || names[0] != "b" || names[1] != "token" {
+		t.Fatalf("query-param-names should be [b token] but was %v", names)
+	}
+
+	empty := observed.All()[1].ContextMap()
+	if _, ok := empty["query-param-count"]; ok {
+		t.Fatal("query-param-count should be omitted without params")
+	}
+	if _, ok := empty["query-param-names"]; ok {
+		t.Fatal("query-param-names should be omitted without params")
+	}
+}
+
+func TestIncludeRuntimeStatsOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{IncludeRuntimeStatsOnError: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("out of memory"))
+		c.Status(500)
+	})
+
+	for _, path := range []string{testPath, "/fail"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if _, ok := observed.All()[0].ContextMap()["heap-alloc"]; ok {
+		t.Fatal("runtime stats should only be read on the error path")
+	}
+
+	fields := observed.All()[1].ContextMap()
+	for _, key := range []string{"heap-alloc", "heap-inuse", "num-gc", "goroutines"} {
+		if _, ok := fields[key]; !ok {
+			t.Fatalf("%s should be logged on the error path", key)
+		}
+	}
+	if heap := fields["heap-alloc"].(uint64); heap == 0 {
+		t.Fatal("heap-alloc should be non-zero")
+	}
+}
+
+func TestIncludeGoroutineID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{IncludeGoroutineID: true}))
+	r.Use(RecoveryWithZap(zap.NewNop(), false))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("failed"))
+		c.Status(500)
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	for _, path := range []string{testPath, "/fail", "/panic"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if _, ok := observed.All()[0].ContextMap()["goroutine"]; ok {
+		t.Fatal("goroutine should only be logged on the error and panic paths")
+	}
+	for _, entry := range observed.All()[1:] {
+		if id, ok := entry.ContextMap()["goroutine"].(uint64); !ok || id == 0 {
+			t.Fatalf("goroutine should be logged for %v", entry.ContextMap()["path"])
+		}
+	}
+}
+
+func TestDurationFormatter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		DurationFormatter: func(d time.Duration) string {
+			return "formatted"
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	latency := observed.All()[0].Context[6]
+	if latency.Key != "latency" || latency.String != "formatted" {
+		t.Fatalf("latency should be the formatted string but was %v", latency)
+	}
+}
+
+func TestLogIdempotencyKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	defaultLogger, defaultObserved := buildDummyLogger()
+	customLogger, customObserved := buildDummyLogger()
+	r.Use(GinzapWithConfig(defaultLogger, &Config{LogIdempotencyKey: true}))
+	r.Use(GinzapWithConfig(customLogger, &Config{LogIdempotencyKey: true, IdempotencyKeyHeader: "X-Idempotency-Token"}))
+
+	r.POST(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "POST", testPath, nil)
+	req.Header.Set("Idempotency-Key", "key-1")
+	r.ServeHTTP(res, req)
+
+	if key := defaultObserved.All()[0].ContextMap()["idempotency-key"]; key != "key-1" {
+		t.Fatalf("idempotency-key should be key-1 but was %v", key)
+	}
+	if _, ok := customObserved.All()[0].ContextMap()["idempotency-key"]; ok {
+		t.Fatal("idempotency-key should be omitted when the configured header is absent")
+	}
+}
+
+func TestLogStatic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{LogStatic: true}))
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.Static("/assets", dir)
+	r.StaticFile("/favicon.ico", filepath.Join(dir, "app.js"))
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, path := range []string{"/assets/app.js", "/favicon.ico", testPath} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	for i, want := range []bool{true, true, false} {
+		_, static := observed.All()[i].ContextMap()["static"]
+		if static != want {
+			t.Fatalf("line %d: static should be %v", i, want)
+		}
+	}
+}
+
+func TestFeatureFlags(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{FeatureFlagsContextKey: "flags"}))
+
+	r.GET("/map", func(c *gin.Context) {
+		c.Set("flags", map[string]bool{"new-checkout": true})
+	})
+	r.GET("/slice", func(c *gin.Context) {
+		c.Set("flags", []string{"dark-mode"})
+	})
+	r.GET("/wrong", func(c *gin.Context) {
+		c.Set("flags", "dark-mode")
+	})
+
+	for _, path := range []string{"/map", "/slice", "/wrong"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if flags, ok := observed.All()[0].ContextMap()["feature-flags"].(map[string]bool); !ok || !flags["new-checkout"] {
+		t.Fatalf("feature-flags should hold the map but was %v", observed.All()[0].ContextMap()["feature-flags"])
+	}
+	if flags, ok := observed.All()[1].ContextMap()["feature-flags"].([]interface{}); !ok || flags[0] != "dark-mode" {
+		t.Fatalf("feature-flags should hold the slice but was %v", observed.All()[1].ContextMap()["feature-flags"])
+	}
+	if _, ok := observed.All()[2].ContextMap()["feature-flags"]; ok {
+		t.Fatal("feature-flags should be omitted for unsupported types")
+	}
+}
+
+func TestLogRequestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	core, observed := observer.New(zap.DebugLevel)
+	r.Use(GinzapWithConfig(zap.New(core), &Config{LogRequestStart: true, SkipPaths: []string{"/no_log"}}))
+
+	var startedBeforeHandler bool
+	r.GET(testPath, func(c *gin.Context) {
+		startedBeforeHandler = len(observed.All()) == 1
+		c.JSON(204, nil)
+	})
+	r.GET("/no_log", func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	for _, path := range []string{testPath, "/no_log"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		req.Header.Set("X-Request-Id", "req-1")
+		r.ServeHTTP(res, req)
+	}
+
+	if !startedBeforeHandler {
+		t.Fatal("the start line should be written before the handler runs")
+	}
+	if len(observed.All()) != 2 {
+		t.Fatalf("Log should be 2 lines but there're %d", len(observed.All()))
+	}
+
+	start := observed.All()[0]
+	if start.Level != zapcore.DebugLevel || start.Message != "request started" {
+		t.Fatalf("unexpected start line %s %q", start.Level, start.Message)
+	}
+	fields := start.ContextMap()
+	if fields["method"] != "GET" || fields["path"] != testPath || fields["request-id"] != "req-1" {
+		t.Fatalf("unexpected start line fields %v", fields)
+	}
+}
+
+func TestParseUpstreamTiming(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 120 * time.Millisecond, true},
+		{"1.5", 1500 * time.Microsecond, true},
+		{"250ms", 250 * time.Millisecond, true},
+		{" 2s ", 2 * time.Second, true},
+		{"", 0, false},
+		{"fast", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseUpstreamTiming(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseUpstreamTiming(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUpstreamTimingHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{UpstreamTimingHeader: "X-Upstream-Time"}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		time.Sleep(3 * time.Millisecond)
+		c.Header("X-Upstream-Time", "1")
+		c.JSON(200, nil)
+	})
+	r.GET("/direct", func(c *gin.Context) {
+		c.JSON(200, nil)
+	})
+
+	for _, path := range []string{testPath, "/direct"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	fields := observed.All()[0].ContextMap()
+	latency := fields["latency"].(time.Duration)
+	if fields["upstream-latency"] != time.Millisecond {
+		t.Fatalf("upstream-latency should be 1ms but was %v", fields["upstream-latency"])
+	}
+	if fields["proxy-overhead"] != latency-time.Millisecond {
+		t.Fatalf("proxy-overhead should be %v but was %v", latency-time.Millisecond, fields["proxy-overhead"])
+	}
+
+	if _, ok := observed.All()[1].ContextMap()["upstream-latency"]; ok {
+		t.Fatal("upstream-latency should be omitted without the header")
+	}
+}
+
+func TestTimeFormats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		TimeFormat: time.RFC3339,
+		UTC:        true,
+		TimeFormats: map[string]string{
+			"date": "2006-01-02",
+			"time": "15:04:05",
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	fields := observed.All()[0].ContextMap()
+	if _, err := time.Parse("2006-01-02", fields["date"].(string)); err != nil {
+		t.Fatalf("date should use its own layout: %v", err)
+	}
+	if _, err := time.Parse("15:04:05", fields["time"].(string)); err != nil {
+		t.Fatalf("the time entry should override TimeFormat: %v", err)
+	}
+	if fields["date"] != time.Now().UTC().Format("2006-01-02") {
+		t.Fatalf("date should respect UTC but was %v", fields["date"])
+	}
+}
+
+func TestTimeFormatFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		TimeFormat: time.RFC3339,
+		Clock:      func() time.Time { return now },
+		TimeFormatFunc: func(c *gin.Context) string {
+			switch c.Request.URL.Path {
+			case "/audit":
+				return time.RFC3339Nano
+			case "/quiet":
+				return ""
+			}
+			return time.RFC3339
+		},
+	}))
+
+	for _, path := range []string{"/audit", testPath, "/quiet"} {
+		r.GET(path, func(c *gin.Context) {
+			c.Status(204)
+		})
+	}
+
+	for _, path := range []string{"/audit", testPath, "/quiet"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if got := observed.All()[0].ContextMap()["time"]; got != "2024-05-01T12:30:00.123456789Z" {
+		t.Fatalf("/audit should log nanoseconds but logged %v", got)
+	}
+	if got := observed.All()[1].ContextMap()["time"]; got != "2024-05-01T12:30:00Z" {
+		t.Fatalf("%s should log seconds but logged %v", testPath, got)
+	}
+	if got, ok := observed.All()[2].ContextMap()["time"]; ok {
+		t.Fatalf("an empty layout should omit time but logged %v", got)
+	}
+}
+
+func TestLogEndTime(t *testing.T) {
+	r := gin.New()
+
+	end := time.Date(2024, 5, 1, 14, 30, 0, 123456789, time.FixedZone("CEST", 2*3600))
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		LogEndTime: true,
+		UTC:        true,
+		Clock:      func() time.Time { return end },
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	got, ok := observed.All()[0].ContextMap()["request-end"].(time.Time)
+	if !ok || !got.Equal(end) || got.Location() != time.UTC {
+		t.Fatalf("request-end should be the end instant in UTC but was %v", observed.All()[0].ContextMap()["request-end"])
+	}
+	if _, ok := observed.All()[0].ContextMap()["time"]; ok {
+		t.Fatal("request-end should not need TimeFormat")
+	}
+}
+
+func TestInternalPanic(t *testing.T) {
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{
+		Context: func(c *gin.Context) []zapcore.Field {
+			var m map[string]int
+			m["boom"]++
+			return nil
+		},
+	}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", testPath, nil)
+	r.ServeHTTP(res, req)
+
+	if res.Code != 200 || res.Body.String() != "ok" {
+		t.Fatalf("the response should be unaffected, got %d %q", res.Code, res.Body.String())
+	}
+	if observed.Len() != 1 {
+		t.Fatalf("a fallback line should be logged, got %d entries", observed.Len())
+	}
+	entry := observed.All()[0]
+	fields := entry.ContextMap()
+	if entry.Message != "ginzap internal panic" || entry.Level != zapcore.ErrorLevel || fields["status"] != int64(200) || fields["path"] != testPath || fields["error"] == nil {
+		t.Fatalf("unexpected fallback line %q %s %v", entry.Message, entry.Level, fields)
+	}
+}
+
+var allocSink [][]byte
+
+func TestIncludeAllocStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{IncludeAllocStats: true}))
+
+	r.GET(testPath, func(c *gin.Context) {
+		for i := 0; i < 100; i++ {
+			allocSink = append(allocSink, make([]byte, 64))
+		}
+		c.JSON(204, nil)
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", testPath, nil)
+	r.ServeHTTP(res, req)
+	allocSink = nil
+
+	if allocs, ok := observed.All()[0].ContextMap()["allocs"].(uint64); !ok || allocs < 100 {
+		t.Fatalf("allocs should count the handler allocations but was %v", observed.All()[0].ContextMap()["allocs"])
+	}
+}
+
+func TestCostContextKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := gin.New()
+
+	logger, observed := buildDummyLogger()
+	r.Use(GinzapWithConfig(logger, &Config{CostContextKey: "cost"}))
+
+	r.GET("/int", func(c *gin.Context) {
+		c.Set("cost", 5)
+	})
+	r.GET("/float", func(c *gin.Context) {
+		c.Set("cost", 0.25)
+	})
+	r.GET("/wrong", func(c *gin.Context) {
+		c.Set("cost", "5")
+	})
+
+	for _, path := range []string{"/int", "/float", "/wrong"} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+		r.ServeHTTP(res, req)
+	}
+
+	if cost := observed.All()[0].ContextMap()["cost"]; cost != int64(5) {
+		t.Fatalf("cost should be 5 but was %v", cost)
+	}
+	if cost := observed.All()[1].ContextMap()["cost"]; cost != 0.25 {
+		t.Fatalf("cost should be 0.25 but was %v", cost)
+	}
+	if _, ok := observed.All()[2].ContextMap()["cost"]; ok {
+		t.Fatal("cost should be omitted for non-numeric values")
+	}
+}
+
+func BenchmarkGinzap(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.DebugMode)
+
+	r := gin.New()
+	r.Use(Ginzap(zap.NewNop(), time.RFC3339, true))
+	r.GET(testPath, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest("GET", testPath+"?a=1", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// BenchmarkGinzapSkipped measures a SkipPaths request with body logging on,
+// whose body is not read.
+func BenchmarkGinzapSkipped(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.DebugMode)
+
+	r := gin.New()
+	r.Use(GinzapWithConfig(zap.NewNop(), &Config{
+		SkipPaths:       []string{testPath},
+		LogRequestBody:  true,
+		LogResponseBody: true,
+	}))
+	r.POST(testPath, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	body := []byte(`{"id":1,"name":"benchmark"}`)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", testPath, bytes.NewReader(body)))
+	}
+}